@@ -23,8 +23,38 @@ func main() {
 	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
 	defer stop()
 
-	if err := domino.Run(ctx, cfg); err != nil {
+	if cfg.IsServe() {
+		if err := domino.Serve(ctx, cfg); err != nil {
+			stderr("%s\n", err.Error())
+			os.Exit(1)
+		}
+		return
+	}
+
+	if cfg.IsWatch() {
+		if err := domino.Watch(ctx, cfg); err != nil {
+			stderr("%s\n", err.Error())
+			os.Exit(1)
+		}
+		return
+	}
+
+	run := domino.RunWithResult
+	if cfg.IsAction() {
+		run = domino.RunAction
+	}
+	result, err := run(ctx, cfg)
+	if err != nil {
 		stderr("%s\n", err.Error())
 		os.Exit(1)
 	}
+	if cfg.IsAction() {
+		os.Exit(result.ActionExitCode())
+	}
+	if cfg.IsCI() {
+		os.Exit(result.ExitCode())
+	}
+	if result == domino.ResultConflict || result == domino.ResultBroken || result == domino.ResultFailed {
+		os.Exit(1)
+	}
 }
@@ -0,0 +1,192 @@
+package git
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/134130/gh-domino/gitobj"
+)
+
+// Cache keeps the metadata of the PRs on disk between runs, so that unchanged PRs are not fetched again.
+type Cache struct {
+	dir string
+}
+
+// MetadataCache is the cache of the PR metadata, or nil to always fetch it.
+var MetadataCache *Cache
+
+const (
+	// cacheVersion is bumped whenever the format of the cache files changes, to ignore the older ones.
+	cacheVersion = 1
+	// defaultBranchTTL is how long the default branch of the remote is trusted, as it hardly ever changes.
+	defaultBranchTTL = 24 * time.Hour
+)
+
+// NewCache returns a cache stored in the directory.
+func NewCache(dir string) *Cache {
+	return &Cache{dir: dir}
+}
+
+// OpenCache returns the cache of the repository, in .git/domino/cache, shared by its worktrees.
+func OpenCache(ctx context.Context) (*Cache, error) {
+	stdout := &bytes.Buffer{}
+	args := []string{"rev-parse", "--path-format=absolute", "--git-common-dir"}
+	if err := NewCommand("git", args...).Run(ctx, WithStdout(stdout)); err != nil {
+		return nil, err
+	}
+	return NewCache(filepath.Join(strings.TrimSpace(stdout.String()), "domino", "cache")), nil
+}
+
+// cacheFile is the envelope of each cache file.
+type cacheFile[T any] struct {
+	Version int `json:"version"`
+	Data    T   `json:"data"`
+}
+
+// load reads the entry, and tells whether it was there. An unreadable entry is treated as a missing one.
+func load[T any](c *Cache, name string) (T, bool) {
+	var f cacheFile[T]
+	data, err := os.ReadFile(filepath.Join(c.dir, name))
+	if err != nil || json.Unmarshal(data, &f) != nil || f.Version != cacheVersion {
+		var zero T
+		return zero, false
+	}
+	return f.Data, true
+}
+
+// store writes the entry through a temporary file, so that concurrent runs never read a partial one.
+func store[T any](c *Cache, name string, v T) error {
+	data, err := json.Marshal(cacheFile[T]{Version: cacheVersion, Data: v})
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(c.dir, 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(c.dir, name+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) //nolint:errcheck
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), filepath.Join(c.dir, name))
+}
+
+// prUpdate tells when a PR was last updated, which changes with any push, edit or label.
+type prUpdate struct {
+	Number    int    `json:"number"`
+	UpdatedAt string `json:"updatedAt"`
+}
+
+type openPullRequests struct {
+	Updates      []prUpdate           `json:"updates"`
+	PullRequests []gitobj.PullRequest `json:"pullRequests"`
+}
+
+// cachedPullRequests returns the open PRs of the author from the cache, if none of them was updated,
+// opened or closed since they were stored. It returns the last updates of the PRs to store along with
+// them otherwise.
+func (c *Cache) cachedPullRequests(ctx context.Context, author string) ([]gitobj.PullRequest, []prUpdate, bool) {
+	stdout := &bytes.Buffer{}
+	listArgs := []string{"pr", "list", "--author", author, "--json", "number,updatedAt"}
+	if err := NewCommand("gh", listArgs...).Run(ctx, WithStdout(stdout)); err != nil {
+		return nil, nil, false
+	}
+	var updates []prUpdate
+	if err := json.NewDecoder(stdout).Decode(&updates); err != nil {
+		return nil, nil, false
+	}
+	slices.SortFunc(updates, func(a, b prUpdate) int {
+		return a.Number - b.Number
+	})
+
+	cached, ok := load[openPullRequests](c, cacheName("open-prs", author))
+	if !ok || !slices.Equal(cached.Updates, updates) {
+		return nil, updates, false
+	}
+	return cached.PullRequests, nil, true
+}
+
+func (c *Cache) storePullRequests(author string, updates []prUpdate, prs []gitobj.PullRequest) error {
+	return store(c, cacheName("open-prs", author), openPullRequests{Updates: updates, PullRequests: prs})
+}
+
+// cachedMergedPullRequests returns the recently merged PRs of the author from the cache, if they were
+// all stored already. A merged PR never changes, so only the list of their numbers is fetched.
+func (c *Cache) cachedMergedPullRequests(ctx context.Context, author string) ([]gitobj.PullRequest, bool) {
+	stdout := &bytes.Buffer{}
+	listArgs := []string{
+		"pr", "list", "--author", author, "--state", "merged", "--limit", "30", "--json", "number",
+	}
+	if err := NewCommand("gh", listArgs...).Run(ctx, WithStdout(stdout)); err != nil {
+		return nil, false
+	}
+	var numbers []struct {
+		Number int `json:"number"`
+	}
+	if err := json.NewDecoder(stdout).Decode(&numbers); err != nil {
+		return nil, false
+	}
+
+	cached, ok := load[map[int]gitobj.PullRequest](c, cacheName("merged-prs", author))
+	if !ok {
+		return nil, false
+	}
+	prs := make([]gitobj.PullRequest, 0, len(numbers))
+	for _, n := range numbers {
+		pr, ok := cached[n.Number]
+		if !ok {
+			return nil, false
+		}
+		prs = append(prs, pr)
+	}
+	slices.SortFunc(prs, func(a, b gitobj.PullRequest) int {
+		return a.Number - b.Number
+	})
+	return prs, true
+}
+
+// storeMergedPullRequests replaces the merged PRs of the author, dropping the ones which are not recent anymore.
+func (c *Cache) storeMergedPullRequests(author string, prs []gitobj.PullRequest) error {
+	byNumber := make(map[int]gitobj.PullRequest, len(prs))
+	for _, pr := range prs {
+		byNumber[pr.Number] = pr
+	}
+	return store(c, cacheName("merged-prs", author), byNumber)
+}
+
+type defaultBranch struct {
+	Branch    string    `json:"branch"`
+	FetchedAt time.Time `json:"fetchedAt"`
+}
+
+func (c *Cache) cachedDefaultBranch(remote string) (string, bool) {
+	cached, ok := load[defaultBranch](c, cacheName("default-branch", remote))
+	if !ok || cached.Branch == "" || time.Since(cached.FetchedAt) > defaultBranchTTL {
+		return "", false
+	}
+	return cached.Branch, true
+}
+
+func (c *Cache) storeDefaultBranch(remote, branch string) error {
+	return store(c, cacheName("default-branch", remote), defaultBranch{Branch: branch, FetchedAt: time.Now()})
+}
+
+// cacheName returns the file name of the entry for the key, such as an author or a remote.
+func cacheName(kind, key string) string {
+	return fmt.Sprintf("%s-%s.json", kind, url.PathEscape(key))
+}
@@ -65,6 +65,14 @@ func (r *DefaultRunner) Run(ctx context.Context, cmdName string, args []string,
 				ge.ExitCode = exitError.ExitCode()
 			}
 			return &ge
+		case "sh":
+			se := ShellError{err: err}
+			var exitError *exec.ExitError
+			if errors.As(err, &exitError) {
+				se.Stderr = stderr.String()
+				se.ExitCode = exitError.ExitCode()
+			}
+			return &se
 		default:
 			panic(fmt.Sprintf("unsupported command: %s", cmdName))
 		}
@@ -104,6 +112,13 @@ func WithStdout(stdout io.Writer) CommandModifier {
 	}
 }
 
+// WithDir runs the command in the directory rather than the working directory.
+func WithDir(dir string) CommandModifier {
+	return func(c *exec.Cmd) {
+		c.Dir = dir
+	}
+}
+
 func WithStderr(stderr io.Writer) CommandModifier {
 	return func(c *exec.Cmd) {
 		if stderr == nil {
@@ -133,8 +148,20 @@ func WithStdin(stdin io.Reader) CommandModifier {
 	}
 }
 
+// WithEnv adds the variables, formatted as key=value, to the environment of the command.
+func WithEnv(env ...string) CommandModifier {
+	return func(c *exec.Cmd) {
+		if c.Env == nil {
+			c.Env = os.Environ()
+		}
+		c.Env = append(c.Env, env...)
+	}
+}
+
 func path(cmd string) (string, error) {
 	switch cmd {
+	case "sh":
+		return safeexec.LookPath("sh")
 	case "git":
 		return safeexec.LookPath("git")
 	case "gh":
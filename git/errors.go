@@ -2,6 +2,7 @@ package git
 
 import (
 	"fmt"
+	"strings"
 )
 
 var ErrRebaseConflict = fmt.Errorf("rebase conflict")
@@ -19,6 +20,22 @@ func (e *NotInstalledError) Unwrap() error {
 	return e.err
 }
 
+// StaleLeaseError is returned when a force-push is rejected because the remote
+// branch no longer points to the commit it was expected to.
+type StaleLeaseError struct {
+	Branch      string
+	ExpectedSha string
+	err         error
+}
+
+func (e *StaleLeaseError) Error() string {
+	return fmt.Sprintf("someone pushed to %s meanwhile (expected it at %s); fetch the new commits and re-run", e.Branch, e.ExpectedSha)
+}
+
+func (e *StaleLeaseError) Unwrap() error {
+	return e.err
+}
+
 type GitError struct {
 	ExitCode int
 	Stderr   string
@@ -52,3 +69,72 @@ func (ge *GHError) Error() string {
 func (ge *GHError) Unwrap() error {
 	return ge.err
 }
+
+// ShellError is returned when a user-provided command run with sh fails.
+type ShellError struct {
+	ExitCode int
+	Stderr   string
+	err      error
+}
+
+func (se *ShellError) Error() string {
+	if se.ExitCode == 0 {
+		return fmt.Sprintf("failed to run command: %v", se.err)
+	}
+	return fmt.Sprintf("command exited with %d", se.ExitCode)
+}
+
+func (se *ShellError) Unwrap() error {
+	return se.err
+}
+
+// RemoteHeadError is returned when refs/remotes/<remote>/HEAD is missing, which happens when the remote was
+// added rather than cloned.
+type RemoteHeadError struct {
+	Remote string
+	err    error
+}
+
+func (e *RemoteHeadError) Error() string {
+	return fmt.Sprintf("refs/remotes/%s/HEAD is not set", e.Remote)
+}
+
+func (e *RemoteHeadError) Unwrap() error {
+	return e.err
+}
+
+// ProviderDefaultBranchError is returned when GitHub could not tell the default branch of the repository.
+type ProviderDefaultBranchError struct {
+	err error
+}
+
+func (e *ProviderDefaultBranchError) Error() string {
+	if e.err == nil {
+		return "GitHub did not tell the default branch of the repository"
+	}
+	return fmt.Sprintf("could not ask GitHub for the default branch of the repository: %v", e.err)
+}
+
+func (e *ProviderDefaultBranchError) Unwrap() error {
+	return e.err
+}
+
+// DefaultBranchError is returned when the default branch could not be found in any way,
+// and wraps why each of them failed.
+type DefaultBranchError struct {
+	Remote string
+	errs   []error
+}
+
+func (e *DefaultBranchError) Error() string {
+	msgs := make([]string, 0, len(e.errs))
+	for _, err := range e.errs {
+		msgs = append(msgs, err.Error())
+	}
+	return fmt.Sprintf("could not determine the default branch of %s (%s); run `git remote set-head %s --auto` or set default-branch in the settings",
+		e.Remote, strings.Join(msgs, ", "), e.Remote)
+}
+
+func (e *DefaultBranchError) Unwrap() []error {
+	return e.errs
+}
@@ -12,14 +12,44 @@ import (
 	"github.com/134130/gh-domino/gitobj"
 )
 
-func ListPullRequests(ctx context.Context) ([]gitobj.PullRequest, error) {
+// Remote is the name of the remote the PRs are pushed to.
+var Remote = "origin"
+
+// RemoteRef returns the remote-tracking ref of the branch.
+func RemoteRef(branch string) string {
+	return Remote + "/" + branch
+}
+
+// ListPullRequests lists the open PRs of the author, which is a login or "@me".
+// They come from the MetadataCache when none of them changed since the last run.
+func ListPullRequests(ctx context.Context, author string) ([]gitobj.PullRequest, error) {
+	if MetadataCache == nil {
+		return listPullRequests(ctx, author)
+	}
+
+	prs, updates, ok := MetadataCache.cachedPullRequests(ctx, author)
+	if ok {
+		return prs, nil
+	}
+	prs, err := listPullRequests(ctx, author)
+	if err != nil {
+		return nil, err
+	}
+	if updates != nil {
+		// A failure to cache must not fail the run, the PRs are fetched again next time.
+		_ = MetadataCache.storePullRequests(author, updates, prs)
+	}
+	return prs, nil
+}
+
+func listPullRequests(ctx context.Context, author string) ([]gitobj.PullRequest, error) {
 	stdout := &bytes.Buffer{}
 	fields := []string{
 		"number", "title", "url", "author", "state", "isDraft",
-		"mergeCommit", "baseRefName", "headRefName", "headRepository", "commits",
+		"mergeCommit", "baseRefName", "headRefName", "headRepository", "commits", "labels",
 	}
 	listArgs := []string{
-		"pr", "list", "--author", "@me", "--json", strings.Join(fields, ","),
+		"pr", "list", "--author", author, "--json", strings.Join(fields, ","),
 	}
 	if err := NewCommand("gh", listArgs...).Run(ctx, WithStdout(stdout)); err != nil {
 		return nil, err
@@ -37,33 +67,93 @@ func ListPullRequests(ctx context.Context) ([]gitobj.PullRequest, error) {
 	return prs, nil
 }
 
-var defaultBranchCache string
+var (
+	defaultBranchOverride string
+	defaultBranchCache    string
+)
+
+// UseDefaultBranch sets the default branch to trust over the remote, or clears it when empty.
+// Either way, the default branch found before is forgotten.
+func UseDefaultBranch(branch string) {
+	defaultBranchOverride = branch
+	defaultBranchCache = ""
+}
 
+// GetDefaultBranch returns the default branch of the remote. Unless it was set with UseDefaultBranch, it is
+// told by refs/remotes/<remote>/HEAD, or else by the repository on GitHub.
 func GetDefaultBranch(ctx context.Context) (string, error) {
+	if defaultBranchOverride != "" {
+		return defaultBranchOverride, nil
+	}
 	if defaultBranchCache != "" {
 		return defaultBranchCache, nil
 	}
 
-	stdout := &bytes.Buffer{}
-	args := []string{"remote", "show", "origin"}
-	if err := NewCommand("git", args...).Run(ctx, WithStdout(stdout)); err != nil {
-		return "", err
+	branch, headErr := remoteHead(ctx)
+	if headErr == nil {
+		defaultBranchCache = branch
+		return branch, nil
 	}
 
-	for _, line := range strings.Split(stdout.String(), "\n") {
-		if strings.Contains(line, "HEAD branch") {
-			parts := strings.Split(line, ":")
-			if len(parts) > 1 {
-				defaultBranchCache = strings.TrimSpace(parts[1])
-				return defaultBranchCache, nil
-			}
+	if MetadataCache != nil {
+		if branch, ok := MetadataCache.cachedDefaultBranch(Remote); ok {
+			defaultBranchCache = branch
+			return branch, nil
 		}
 	}
+	branch, providerErr := providerDefaultBranch(ctx)
+	if providerErr != nil {
+		return "", &DefaultBranchError{Remote: Remote, errs: []error{headErr, providerErr}}
+	}
+	defaultBranchCache = branch
+	if MetadataCache != nil {
+		_ = MetadataCache.storeDefaultBranch(Remote, branch)
+	}
+	return branch, nil
+}
+
+// remoteHead reads the branch refs/remotes/<remote>/HEAD points to, which git clone and
+// git remote set-head set, without contacting the remote.
+func remoteHead(ctx context.Context) (string, error) {
+	stdout := &bytes.Buffer{}
+	args := []string{"symbolic-ref", "--quiet", "--short", "refs/remotes/" + Remote + "/HEAD"}
+	if err := NewCommand("git", args...).Run(ctx, WithStdout(stdout)); err != nil {
+		return "", &RemoteHeadError{Remote: Remote, err: err}
+	}
+	branch, ok := strings.CutPrefix(strings.TrimSpace(stdout.String()), Remote+"/")
+	if !ok || branch == "" {
+		return "", &RemoteHeadError{Remote: Remote}
+	}
+	return branch, nil
+}
 
-	return "", fmt.Errorf("could not determine default branch")
+// providerDefaultBranch asks GitHub for the default branch of the repository.
+func providerDefaultBranch(ctx context.Context) (string, error) {
+	stdout := &bytes.Buffer{}
+	args := []string{"repo", "view", "--json", "defaultBranchRef", "--jq", ".defaultBranchRef.name"}
+	if err := NewCommand("gh", args...).Run(ctx, WithStdout(stdout)); err != nil {
+		return "", &ProviderDefaultBranchError{err: err}
+	}
+	branch := strings.TrimSpace(stdout.String())
+	if branch == "" {
+		return "", &ProviderDefaultBranchError{}
+	}
+	return branch, nil
 }
 
 func GetBranchCommits(ctx context.Context, base, head string) ([]string, error) {
+	if repo := readOnlyRepository(); repo != nil {
+		if hashes, ok := resolveInProcess(repo, base, head); ok {
+			if revs, err := repo.RevList(hashes[0], hashes[1]); err == nil {
+				commits := make([]string, 0, len(revs))
+				for _, rev := range revs {
+					commits = append(commits, rev.String())
+				}
+				return commits, nil
+			}
+		}
+	}
+
 	stdout := &bytes.Buffer{}
 	args := []string{"log", "--pretty=%H", fmt.Sprintf("%s..%s", base, head)}
 	if err := NewCommand("git", args...).Run(ctx, WithStdout(stdout)); err != nil {
@@ -80,6 +170,16 @@ func GetBranchCommits(ctx context.Context, base, head string) ([]string, error)
 	return commits, nil
 }
 
+// Checkout checks out the branch, or the commit with a detached HEAD.
+func Checkout(ctx context.Context, rev string, detach bool) error {
+	args := []string{"checkout", "--quiet"}
+	if detach {
+		args = append(args, "--detach")
+	}
+	args = append(args, rev)
+	return NewCommand("git", args...).Run(ctx)
+}
+
 func Rebase(ctx context.Context, newBase, upstream, branch string) error {
 	var gitErr *GitError
 
@@ -104,8 +204,69 @@ func AbortRebase(ctx context.Context) error {
 	return NewCommand("git", "rebase", "--abort").Run(ctx)
 }
 
-func Push(ctx context.Context, branch string) error {
-	return NewCommand("git", "push", "--force-with-lease", "origin", branch).Run(ctx)
+// ForceWithLease is a branch to force-push along with the commit it is expected
+// to point to on the remote.
+type ForceWithLease struct {
+	Branch      string
+	ExpectedSha string
+}
+
+func (l ForceWithLease) arg() string {
+	return fmt.Sprintf("--force-with-lease=%s:%s", l.Branch, l.ExpectedSha)
+}
+
+// Push force-pushes the branch, only if it still points to the expected commit on the remote.
+// The expectation is explicit rather than the remote-tracking ref, which may have been
+// refreshed in the background by another fetch, which also makes --force-if-includes redundant.
+// The push options are sent to the remote with --push-option.
+func Push(ctx context.Context, lease ForceWithLease, pushOptions []string) error {
+	args := []string{"push", lease.arg()}
+	args = append(args, pushOptionArgs(pushOptions)...)
+	args = append(args, Remote, lease.Branch)
+	return push(ctx, args, []ForceWithLease{lease})
+}
+
+// PushAtomic force-pushes all the given branches in a single atomic push,
+// so either every branch is updated on the remote or none is.
+func PushAtomic(ctx context.Context, leases []ForceWithLease, pushOptions []string) error {
+	args := []string{"push", "--atomic"}
+	for _, lease := range leases {
+		args = append(args, lease.arg())
+	}
+	args = append(args, pushOptionArgs(pushOptions)...)
+	args = append(args, Remote)
+	for _, lease := range leases {
+		args = append(args, lease.Branch)
+	}
+	return push(ctx, args, leases)
+}
+
+func pushOptionArgs(pushOptions []string) []string {
+	args := make([]string, 0, len(pushOptions))
+	for _, option := range pushOptions {
+		args = append(args, "--push-option="+option)
+	}
+	return args
+}
+
+func push(ctx context.Context, args []string, leases []ForceWithLease) error {
+	err := NewCommand("git", args...).Run(ctx)
+	var gitErr *GitError
+	if err == nil || !errors.As(err, &gitErr) {
+		return err
+	}
+
+	for _, line := range strings.Split(gitErr.Stderr, "\n") {
+		if !strings.Contains(line, "(stale info)") {
+			continue
+		}
+		for _, lease := range leases {
+			if strings.Contains(line, " "+lease.Branch+" -> ") {
+				return &StaleLeaseError{Branch: lease.Branch, ExpectedSha: lease.ExpectedSha, err: err}
+			}
+		}
+	}
+	return err
 }
 
 func Fetch(ctx context.Context, remote string, mods ...CommandModifier) error {
@@ -114,6 +275,14 @@ func Fetch(ctx context.Context, remote string, mods ...CommandModifier) error {
 }
 
 func IsAncestor(ctx context.Context, ancestor, descendant string) (bool, error) {
+	if repo := readOnlyRepository(); repo != nil {
+		if hashes, ok := resolveInProcess(repo, ancestor, descendant); ok {
+			if isAncestor, err := repo.IsAncestor(hashes[0], hashes[1]); err == nil {
+				return isAncestor, nil
+			}
+		}
+	}
+
 	args := []string{"merge-base", "--is-ancestor", ancestor, descendant}
 	err := NewCommand("git", args...).Run(ctx)
 	if err == nil {
@@ -126,14 +295,93 @@ func IsAncestor(ctx context.Context, ancestor, descendant string) (bool, error)
 	return false, err
 }
 
-func ListMergedPullRequests(ctx context.Context) ([]gitobj.PullRequest, error) {
+// IsMergeCommit tells whether the commit has more than one parent.
+func IsMergeCommit(ctx context.Context, rev string) (bool, error) {
+	args := []string{"rev-parse", "--verify", "--quiet", rev + "^2"}
+	err := NewCommand("git", args...).Run(ctx)
+	if err == nil {
+		return true, nil
+	}
+	var gitErr *GitError
+	if errors.As(err, &gitErr) && gitErr.ExitCode == 1 {
+		return false, nil
+	}
+	return false, err
+}
+
+// PatchID returns the stable patch ID of the changes introduced by the commit,
+// which is the same for two commits introducing the same changes, or an empty string if it introduces no changes.
+func PatchID(ctx context.Context, rev string) (string, error) {
+	diff := &bytes.Buffer{}
+	if err := NewCommand("git", "show", "--pretty=format:", "--patch", rev).Run(ctx, WithStdout(diff)); err != nil {
+		return "", err
+	}
+	stdout := &bytes.Buffer{}
+	if err := NewCommand("git", "patch-id", "--stable").Run(ctx, WithStdin(diff), WithStdout(stdout)); err != nil {
+		return "", err
+	}
+	fields := strings.Fields(stdout.String())
+	if len(fields) == 0 {
+		return "", nil
+	}
+	return fields[0], nil
+}
+
+// CommitPatchID is the stable patch ID of the changes introduced by a commit.
+type CommitPatchID struct {
+	Commit  string
+	PatchID string
+}
+
+// PatchIDs returns the patch ID of each commit of the range, newest first, skipping the commits without changes.
+func PatchIDs(ctx context.Context, revRange string) ([]CommitPatchID, error) {
+	log := &bytes.Buffer{}
+	args := []string{"log", "--patch", "--no-color", "--pretty=medium", revRange}
+	if err := NewCommand("git", args...).Run(ctx, WithStdout(log)); err != nil {
+		return nil, err
+	}
+	stdout := &bytes.Buffer{}
+	if err := NewCommand("git", "patch-id", "--stable").Run(ctx, WithStdin(log), WithStdout(stdout)); err != nil {
+		return nil, err
+	}
+
+	var patchIDs []CommitPatchID
+	for _, line := range strings.Split(strings.TrimSpace(stdout.String()), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+		patchIDs = append(patchIDs, CommitPatchID{Commit: fields[1], PatchID: fields[0]})
+	}
+	return patchIDs, nil
+}
+
+// ListMergedPullRequests lists the recently merged PRs of the author, which is a login or "@me".
+// They come from the MetadataCache when they were all fetched in a previous run.
+func ListMergedPullRequests(ctx context.Context, author string) ([]gitobj.PullRequest, error) {
+	if MetadataCache == nil {
+		return listMergedPullRequests(ctx, author)
+	}
+
+	if prs, ok := MetadataCache.cachedMergedPullRequests(ctx, author); ok {
+		return prs, nil
+	}
+	prs, err := listMergedPullRequests(ctx, author)
+	if err != nil {
+		return nil, err
+	}
+	_ = MetadataCache.storeMergedPullRequests(author, prs)
+	return prs, nil
+}
+
+func listMergedPullRequests(ctx context.Context, author string) ([]gitobj.PullRequest, error) {
 	stdout := &bytes.Buffer{}
 	fields := []string{
 		"number", "title", "url", "author", "state", "isDraft",
 		"mergeCommit", "baseRefName", "headRefName", "headRepository", "commits",
 	}
 	listArgs := []string{
-		"pr", "list", "--author", "@me", "--state", "merged", "--limit", "30",
+		"pr", "list", "--author", author, "--state", "merged", "--limit", "30",
 		"--json", strings.Join(fields, ","),
 	}
 	if err := NewCommand("gh", listArgs...).Run(ctx, WithStdout(stdout)); err != nil {
@@ -153,6 +401,14 @@ func ListMergedPullRequests(ctx context.Context) ([]gitobj.PullRequest, error) {
 }
 
 func GetMergeBase(ctx context.Context, branch1, branch2 string) (string, error) {
+	if repo := readOnlyRepository(); repo != nil {
+		if hashes, ok := resolveInProcess(repo, branch1, branch2); ok {
+			if mergeBase, err := repo.MergeBase(hashes[0], hashes[1]); err == nil {
+				return mergeBase.String(), nil
+			}
+		}
+	}
+
 	stdout := &bytes.Buffer{}
 	args := []string{"merge-base", branch1, branch2}
 	if err := NewCommand("git", args...).Run(ctx, WithStdout(stdout)); err != nil {
@@ -161,12 +417,102 @@ func GetMergeBase(ctx context.Context, branch1, branch2 string) (string, error)
 	return strings.TrimSpace(stdout.String()), nil
 }
 
+// MergeTree merges the two commits without touching any ref, the index or the working tree,
+// and returns the resulting tree along with the conflicting files, if any.
+// When mergeBase is empty, git computes the merge base itself.
+func MergeTree(ctx context.Context, mergeBase, branch1, branch2 string) (string, []string, error) {
+	stdout := &bytes.Buffer{}
+	args := []string{"merge-tree", "--write-tree", "--name-only", "--no-messages"}
+	if mergeBase != "" {
+		args = append(args, "--merge-base="+mergeBase)
+	}
+	args = append(args, branch1, branch2)
+
+	err := NewCommand("git", args...).Run(ctx, WithStdout(stdout))
+	var gitErr *GitError
+	if err != nil && (!errors.As(err, &gitErr) || gitErr.ExitCode != 1) {
+		return "", nil, err
+	}
+
+	lines := strings.Split(strings.TrimSpace(stdout.String()), "\n")
+	tree := strings.TrimSpace(lines[0])
+	if err == nil {
+		return tree, nil, nil
+	}
+
+	var conflicts []string
+	for _, line := range lines[1:] {
+		if trimmedLine := strings.TrimSpace(line); trimmedLine != "" {
+			conflicts = append(conflicts, trimmedLine)
+		}
+	}
+	return tree, conflicts, nil
+}
+
+// CommitTree creates a commit for the tree on top of the parent without updating any ref.
+func CommitTree(ctx context.Context, tree, parent, message string) (string, error) {
+	stdout := &bytes.Buffer{}
+	args := []string{"commit-tree", tree, "-p", parent, "-m", message}
+	if err := NewCommand("git", args...).Run(ctx, WithStdout(stdout)); err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(stdout.String()), nil
+}
+
 func UpdateBaseBranch(ctx context.Context, prNumber int, newBase string) error {
 	args := []string{"pr", "edit", fmt.Sprint(prNumber), "--base", newBase}
 	return NewCommand("gh", args...).Run(ctx)
 }
 
+// ClosePullRequest closes the PR with a comment.
+func ClosePullRequest(ctx context.Context, prNumber int, comment string) error {
+	args := []string{"pr", "close", fmt.Sprint(prNumber), "--comment", comment}
+	return NewCommand("gh", args...).Run(ctx)
+}
+
+// HasOpenPullRequests tells whether any open PR, of any author, is based on the branch.
+func HasOpenPullRequests(ctx context.Context, base string) (bool, error) {
+	stdout := &bytes.Buffer{}
+	args := []string{"pr", "list", "--base", base, "--json", "number"}
+	if err := NewCommand("gh", args...).Run(ctx, WithStdout(stdout)); err != nil {
+		return false, err
+	}
+
+	var prs []struct {
+		Number int `json:"number"`
+	}
+	if err := json.NewDecoder(stdout).Decode(&prs); err != nil {
+		return false, fmt.Errorf("failed to decode pr list: %w", err)
+	}
+	return len(prs) > 0, nil
+}
+
+// DeleteRemoteBranch deletes the branch on the remote.
+func DeleteRemoteBranch(ctx context.Context, branch string) error {
+	return NewCommand("git", "push", Remote, "--delete", branch).Run(ctx)
+}
+
+func GetPullRequestBody(ctx context.Context, prNumber int) (string, error) {
+	stdout := &bytes.Buffer{}
+	args := []string{"pr", "view", fmt.Sprint(prNumber), "--json", "body", "--jq", ".body"}
+	if err := NewCommand("gh", args...).Run(ctx, WithStdout(stdout)); err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(stdout.String(), "\n"), nil
+}
+
+func EditPullRequestBody(ctx context.Context, prNumber int, body string) error {
+	args := []string{"pr", "edit", fmt.Sprint(prNumber), "--body", body}
+	return NewCommand("gh", args...).Run(ctx)
+}
+
 func RevParse(ctx context.Context, ref string) (string, error) {
+	if repo := readOnlyRepository(); repo != nil {
+		if hashes, ok := resolveInProcess(repo, ref); ok {
+			return hashes[0].String(), nil
+		}
+	}
+
 	stdout := &bytes.Buffer{}
 	args := []string{"rev-parse", ref}
 	if err := NewCommand("git", args...).Run(ctx, WithStdout(stdout)); err != nil {
@@ -174,3 +520,13 @@ func RevParse(ctx context.Context, ref string) (string, error) {
 	}
 	return strings.TrimSpace(stdout.String()), nil
 }
+
+// GetTopLevel returns the root directory of the working tree.
+func GetTopLevel(ctx context.Context) (string, error) {
+	stdout := &bytes.Buffer{}
+	args := []string{"rev-parse", "--show-toplevel"}
+	if err := NewCommand("git", args...).Run(ctx, WithStdout(stdout)); err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(stdout.String()), nil
+}
@@ -0,0 +1,37 @@
+package git
+
+import (
+	"context"
+)
+
+// InitMirror creates a bare repository in the directory, with the remote to mirror. Its branches are
+// fetched both as remote-tracking branches and as local ones, so that the local branches are reset to
+// the remote ones on each fetch, as long as they are not checked out in a worktree.
+func InitMirror(ctx context.Context, dir, url string) error {
+	if err := NewCommand("git", "init", "--quiet", "--bare", dir).Run(ctx); err != nil {
+		return err
+	}
+	if err := NewCommand("git", "remote", "add", Remote, url).Run(ctx, WithDir(dir)); err != nil {
+		return err
+	}
+	args := []string{"config", "--add", "remote." + Remote + ".fetch", "+refs/heads/*:refs/heads/*"}
+	return NewCommand("git", args...).Run(ctx, WithDir(dir))
+}
+
+// SetRemoteHead sets refs/remotes/<remote>/HEAD to the default branch of the remote, as git clone does.
+func SetRemoteHead(ctx context.Context, dir string) error {
+	return NewCommand("git", "remote", "set-head", Remote, "--auto").Run(ctx, WithDir(dir))
+}
+
+// AddWorktree adds a worktree of the repository in the directory, with a detached HEAD at the revision.
+func AddWorktree(ctx context.Context, repoDir, path, rev string) error {
+	args := []string{"worktree", "add", "--quiet", "--detach", path, rev}
+	return NewCommand("git", args...).Run(ctx, WithDir(repoDir))
+}
+
+// DetachWorktree aborts the rebase in progress in the worktree if any, and detaches its HEAD at the revision,
+// dropping whatever changes were left in it.
+func DetachWorktree(ctx context.Context, dir, rev string) error {
+	_ = NewCommand("git", "rebase", "--abort").Run(ctx, WithDir(dir))
+	return NewCommand("git", "checkout", "--quiet", "--force", "--detach", rev).Run(ctx, WithDir(dir))
+}
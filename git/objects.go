@@ -0,0 +1,51 @@
+package git
+
+import (
+	"os"
+	"sync"
+
+	"github.com/134130/gh-domino/internal/odb"
+)
+
+var (
+	objectsMu  sync.Mutex
+	objectsDir string
+	objects    *odb.Repository
+)
+
+// readOnlyRepository returns the repository of the working directory, to answer the read-only
+// queries in-process rather than forking git for each of them. It returns nil when the commands
+// are mocked or recorded, as they must all go through the CommandRunner then, or when the
+// repository can't be read in-process.
+// Whatever the repository can't answer on its own is left to the git CLI by the callers.
+func readOnlyRepository() *odb.Repository {
+	if _, ok := CommandRunner.(*DefaultRunner); !ok {
+		return nil
+	}
+	dir, err := os.Getwd()
+	if err != nil {
+		return nil
+	}
+
+	objectsMu.Lock()
+	defer objectsMu.Unlock()
+	// The working directory changes when operating on a mirror, so the repository is opened again then.
+	if dir != objectsDir {
+		objectsDir = dir
+		objects, _ = odb.Open(dir)
+	}
+	return objects
+}
+
+// resolveInProcess resolves the revisions in-process, if they all can be.
+func resolveInProcess(repo *odb.Repository, revs ...string) ([]odb.Hash, bool) {
+	hashes := make([]odb.Hash, 0, len(revs))
+	for _, rev := range revs {
+		h, err := repo.RevParse(rev)
+		if err != nil {
+			return nil, false
+		}
+		hashes = append(hashes, h)
+	}
+	return hashes, true
+}
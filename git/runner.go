@@ -8,6 +8,7 @@ import (
 	"os"
 	"os/exec"
 	"strings"
+	"sync"
 
 	"github.com/goccy/go-yaml"
 )
@@ -22,6 +23,8 @@ type commandLog struct {
 type LoggingRunner struct {
 	r   Runner
 	out string
+	// mu serializes the writes to the log file, as commands may be run concurrently.
+	mu sync.Mutex
 }
 
 func NewLoggingRunner(out string) (*LoggingRunner, error) {
@@ -38,14 +41,6 @@ func NewLoggingRunner(out string) (*LoggingRunner, error) {
 var _ Runner = (*LoggingRunner)(nil)
 
 func (r *LoggingRunner) Run(ctx context.Context, cmd string, args []string, mods ...CommandModifier) error {
-	f, err := os.OpenFile(r.out, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
-	if err != nil {
-		return fmt.Errorf("failed to open log file: %w", err)
-	}
-
-	w := yaml.NewEncoder(f)
-	defer w.Close() //nolint:errcheck
-
 	log := &commandLog{
 		Command: cmd + " " + strings.Join(args, " "),
 	}
@@ -66,7 +61,7 @@ func (r *LoggingRunner) Run(ctx context.Context, cmd string, args []string, mods
 		}
 	}
 
-	err = r.r.Run(ctx, cmd, args, append(mods, mod)...)
+	err := r.r.Run(ctx, cmd, args, append(mods, mod)...)
 
 	log.Stdout = stdout.String()
 	log.Stderr = stderr.String()
@@ -78,6 +73,8 @@ func (r *LoggingRunner) Run(ctx context.Context, cmd string, args []string, mods
 			exitCode = gErr.ExitCode
 		case *GHError:
 			exitCode = gErr.ExitCode
+		case *ShellError:
+			exitCode = gErr.ExitCode
 		default:
 			exitCode = 1
 		}
@@ -93,6 +90,17 @@ func (r *LoggingRunner) Run(ctx context.Context, cmd string, args []string, mods
 		}
 	}
 
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	f, openErr := os.OpenFile(r.out, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if openErr != nil {
+		return fmt.Errorf("failed to open log file: %w", openErr)
+	}
+	defer f.Close() //nolint:errcheck
+
+	w := yaml.NewEncoder(f)
+	defer w.Close() //nolint:errcheck
 	if err := w.Encode([]*commandLog{log}); err != nil {
 		return fmt.Errorf("failed to write log: %w", err)
 	}
@@ -38,6 +38,19 @@ type PullRequest struct {
 	Commits     []struct {
 		Oid string `json:"oid"`
 	} `json:"commits"`
+	Labels []struct {
+		Name string `json:"name"`
+	} `json:"labels"`
+}
+
+// HasLabel tells whether the PR is labeled with the given name.
+func (pr PullRequest) HasLabel(name string) bool {
+	for _, label := range pr.Labels {
+		if label.Name == name {
+			return true
+		}
+	}
+	return false
 }
 
 func (pr PullRequest) String() string {
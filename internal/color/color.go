@@ -2,6 +2,7 @@ package color
 
 import (
 	"fmt"
+	"sync/atomic"
 
 	"github.com/charmbracelet/lipgloss"
 )
@@ -18,38 +19,56 @@ var (
 	grey          = lipgloss.NewStyle().Foreground(lipgloss.ANSIColor(7))
 )
 
+var disabled atomic.Bool
+
+// Disable makes all the functions of this package return the text as is, without ANSI sequences,
+// until the returned function is called to restore how they were before.
+func Disable() (restore func()) {
+	was := disabled.Swap(true)
+	return func() {
+		disabled.Store(was)
+	}
+}
+
+func render(style lipgloss.Style, a ...interface{}) string {
+	if disabled.Load() {
+		return fmt.Sprint(a...)
+	}
+	return style.Render(fmt.Sprint(a...))
+}
+
 func Bold(a ...interface{}) string {
-	return bold.Render(fmt.Sprint(a...))
+	return render(bold, a...)
 }
 
 func Strikethrough(a ...interface{}) string {
-	return strikethrough.Render(fmt.Sprint(a...))
+	return render(strikethrough, a...)
 }
 
 func Blue(a ...interface{}) string {
-	return blue.Render(fmt.Sprint(a...))
+	return render(blue, a...)
 }
 
 func Cyan(a ...interface{}) string {
-	return cyan.Render(fmt.Sprint(a...))
+	return render(cyan, a...)
 }
 
 func Green(a ...interface{}) string {
-	return green.Render(fmt.Sprint(a...))
+	return render(green, a...)
 }
 
 func Red(a ...interface{}) string {
-	return red.Render(fmt.Sprint(a...))
+	return render(red, a...)
 }
 
 func Yellow(a ...interface{}) string {
-	return yellow.Render(fmt.Sprint(a...))
+	return render(yellow, a...)
 }
 
 func Purple(a ...interface{}) string {
-	return purple.Render(fmt.Sprint(a...))
+	return render(purple, a...)
 }
 
 func Grey(a ...interface{}) string {
-	return grey.Render(fmt.Sprint(a...))
+	return render(grey, a...)
 }
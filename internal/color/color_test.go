@@ -0,0 +1,20 @@
+package color
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDisable(t *testing.T) {
+	restore := Disable()
+	assert.True(t, disabled.Load())
+
+	// Nested calls restore the state they found.
+	restoreNested := Disable()
+	restoreNested()
+	assert.True(t, disabled.Load())
+
+	restore()
+	assert.False(t, disabled.Load())
+}
@@ -0,0 +1,206 @@
+package domino
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/134130/gh-domino/gitobj"
+	"github.com/134130/gh-domino/internal/progress"
+)
+
+const (
+	// actionCommitterName and actionCommitterEmail are the identity of the rebased commits in a workflow,
+	// where git has none configured.
+	actionCommitterName  = "github-actions[bot]"
+	actionCommitterEmail = "41898282+github-actions[bot]@users.noreply.github.com"
+)
+
+// RunAction cascades the children of the PR whose merge triggered the GitHub Actions workflow, in the
+// repository checked out by the workflow, and writes a summary of the run to the job summary.
+func RunAction(ctx context.Context, cfg Config) (Result, error) {
+	eventPath := os.Getenv("GITHUB_EVENT_PATH")
+	if eventPath == "" {
+		return ResultFailed, fmt.Errorf("GITHUB_EVENT_PATH is not set, action must be run by a GitHub Actions workflow")
+	}
+	payload, err := os.ReadFile(eventPath)
+	if err != nil {
+		return ResultFailed, fmt.Errorf("failed to read the event of the workflow: %w", err)
+	}
+	var event pullRequestEvent
+	if err := json.Unmarshal(payload, &event); err != nil {
+		return ResultFailed, fmt.Errorf("failed to parse the event of the workflow: %w", err)
+	}
+	job, ok := event.job()
+	if !ok {
+		_, _ = fmt.Fprintln(cfg.Writer, "The workflow was not triggered by a merged PR, nothing to cascade.")
+		return ResultNothingToDo, nil
+	}
+	spec, err := parseRepo(job.Repo)
+	if err != nil {
+		return ResultFailed, err
+	}
+	if err := setupActionAuth(spec.Host); err != nil {
+		return ResultFailed, err
+	}
+
+	summary := &actionSummary{}
+	jobCfg := cfg
+	jobCfg.Command = ""
+	jobCfg.CI = ptr(true)
+	jobCfg.MergedPRs = []int{job.MergedPR}
+	// The workflow runs as github-actions[bot], which is not who the stack belongs to.
+	jobCfg.FallbackAuthor = job.Author
+	jobCfg.Observer = summary
+
+	result, err := RunWithResult(ctx, jobCfg)
+	if path := os.Getenv("GITHUB_STEP_SUMMARY"); path != "" {
+		if writeErr := summary.writeTo(path, job, result, err); writeErr != nil && err == nil {
+			return result, fmt.Errorf("failed to write the job summary: %w", writeErr)
+		}
+	}
+	return result, err
+}
+
+// setupActionAuth makes gh and git use the token given to the workflow, as neither of them is logged in.
+func setupActionAuth(host string) error {
+	token := os.Getenv("GH_TOKEN")
+	if token == "" {
+		token = os.Getenv("GITHUB_TOKEN")
+	}
+	if token == "" {
+		return fmt.Errorf("GH_TOKEN must be set to a token allowed to push to the repository, such as ${{ github.token }}")
+	}
+	if err := os.Setenv("GH_TOKEN", token); err != nil {
+		return err
+	}
+	if host != "github.com" && os.Getenv("GH_ENTERPRISE_TOKEN") == "" {
+		if err := os.Setenv("GH_ENTERPRISE_TOKEN", token); err != nil {
+			return err
+		}
+	}
+
+	// git pushes with the token through gh, unless actions/checkout persisted its own credentials.
+	if err := addGitConfig("credential.https://"+host+".helper", ""); err != nil {
+		return err
+	}
+	if err := addGitConfig("credential.https://"+host+".helper", "!gh auth git-credential"); err != nil {
+		return err
+	}
+
+	if os.Getenv("GIT_COMMITTER_NAME") == "" && os.Getenv("GIT_COMMITTER_EMAIL") == "" {
+		if err := os.Setenv("GIT_COMMITTER_NAME", actionCommitterName); err != nil {
+			return err
+		}
+		if err := os.Setenv("GIT_COMMITTER_EMAIL", actionCommitterEmail); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addGitConfig sets a configuration of the git commands run from now on, through the environment.
+func addGitConfig(key, value string) error {
+	count, _ := strconv.Atoi(os.Getenv("GIT_CONFIG_COUNT"))
+	if err := os.Setenv(fmt.Sprintf("GIT_CONFIG_KEY_%d", count), key); err != nil {
+		return err
+	}
+	if err := os.Setenv(fmt.Sprintf("GIT_CONFIG_VALUE_%d", count), value); err != nil {
+		return err
+	}
+	return os.Setenv("GIT_CONFIG_COUNT", strconv.Itoa(count+1))
+}
+
+// actionSummary records what happened to each PR during the run, to write it to the job summary.
+type actionSummary struct {
+	prs      []gitobj.PullRequest
+	outcomes map[int][]string
+	failures []string
+}
+
+var _ progress.Reporter = (*actionSummary)(nil)
+
+func (s *actionSummary) Report(e progress.Event) {
+	switch e := e.(type) {
+	case progress.PRRebased:
+		s.add(e.PR, fmt.Sprintf("rebased onto `%s`", e.NewBase))
+	case progress.RebaseFailed:
+		s.add(e.PR, fmt.Sprintf("failed to rebase onto `%s`", e.NewBase))
+	case progress.PRPushed:
+		for _, pr := range e.PRs {
+			s.add(pr, "pushed")
+		}
+	case progress.PushFailed:
+		for _, pr := range e.PRs {
+			s.add(pr, "failed to push")
+		}
+	case progress.BaseUpdated:
+		s.add(e.PR, fmt.Sprintf("retargeted to `%s`", e.NewBase))
+	case progress.BaseUpdateFailed:
+		s.add(e.PR, fmt.Sprintf("failed to retarget to `%s`", e.NewBase))
+	case progress.PRClosed:
+		s.add(e.PR, "closed as it had no changes left")
+	case progress.CloseFailed:
+		s.add(e.PR, "failed to close")
+	case progress.Failure:
+		s.failures = append(s.failures, e.Text)
+	}
+}
+
+func (s *actionSummary) Close() {}
+
+func (s *actionSummary) add(pr gitobj.PullRequest, outcome string) {
+	if s.outcomes == nil {
+		s.outcomes = make(map[int][]string)
+	}
+	if _, ok := s.outcomes[pr.Number]; !ok {
+		s.prs = append(s.prs, pr)
+	}
+	s.outcomes[pr.Number] = append(s.outcomes[pr.Number], outcome)
+}
+
+// markdown renders the summary of the cascade of the children of the merged PR.
+func (s *actionSummary) markdown(job Job, result Result, err error) string {
+	b := &strings.Builder{}
+	_, _ = fmt.Fprintf(b, "### gh-domino\n\n")
+	_, _ = fmt.Fprintf(b, "Cascaded the children of #%d: **%s**.\n\n", job.MergedPR, result)
+	if len(s.prs) > 0 {
+		_, _ = fmt.Fprintf(b, "| PR | Outcome |\n| --- | --- |\n")
+		for _, pr := range s.prs {
+			outcome := strings.Join(s.outcomes[pr.Number], ", ")
+			outcome = strings.ToUpper(outcome[:1]) + outcome[1:]
+			_, _ = fmt.Fprintf(b, "| #%d %s | %s |\n", pr.Number, escapeTableCell(pr.Title), outcome)
+		}
+		_, _ = fmt.Fprintf(b, "\n")
+	} else if err == nil && len(s.failures) == 0 {
+		_, _ = fmt.Fprintf(b, "No broken PRs were found.\n\n")
+	}
+	failures := s.failures
+	if err != nil {
+		failures = append(failures, err.Error())
+	}
+	for _, failure := range failures {
+		_, _ = fmt.Fprintf(b, "```\n%s\n```\n\n", failure)
+	}
+	return b.String()
+}
+
+// writeTo appends the summary to the job summary file of the workflow.
+func (s *actionSummary) writeTo(path string, job Job, result Result, err error) error {
+	f, openErr := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if openErr != nil {
+		return openErr
+	}
+	if _, writeErr := f.WriteString(s.markdown(job, result, err)); writeErr != nil {
+		_ = f.Close()
+		return writeErr
+	}
+	return f.Close()
+}
+
+func escapeTableCell(s string) string {
+	return strings.NewReplacer("|", `\|`, "\n", " ").Replace(s)
+}
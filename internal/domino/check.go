@@ -0,0 +1,64 @@
+package domino
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/134130/gh-domino/internal/color"
+	"github.com/134130/gh-domino/internal/stackedpr"
+)
+
+// runCheck reports each broken PR with the reason why it is broken, without changing anything.
+func (c *cascade) runCheck(ctx context.Context, roots []*stackedpr.Node) (Result, error) {
+	broken := 0
+	failed := false
+	for _, p := range c.plan(ctx, roots) {
+		switch {
+		case p.err != nil:
+			c.failure(fmt.Sprintf("%s: %v", p.node.Value.String(), p.err))
+			failed = true
+		case p.rebase != nil:
+			c.failure(fmt.Sprintf("%s: %s", p.node.Value.String(), p.rebase.Diagnosis.Describe(*p.rebase)))
+			if c.cfg.IsVerbose() {
+				c.printDiagnosis(*p.rebase, "  ")
+			}
+			broken++
+		}
+	}
+
+	if failed {
+		return ResultFailed, nil
+	}
+	if broken == 0 {
+		c.success("No broken PRs found.")
+		return ResultNothingToDo, nil
+	}
+	return ResultBroken, nil
+}
+
+// runExplain reports why the PR given in the config is broken or not, with the evidence the decision was based on.
+func (c *cascade) runExplain(ctx context.Context, roots []*stackedpr.Node) (Result, error) {
+	for _, p := range c.plan(ctx, roots) {
+		pr := p.node.Value
+		if pr.Number != c.cfg.PRNumber {
+			continue
+		}
+		if p.err != nil {
+			return ResultFailed, fmt.Errorf("could not tell whether %s is broken: %w", pr.PRNumberString(), p.err)
+		}
+
+		if p.rebase == nil {
+			c.success(fmt.Sprintf("%s is not broken", pr.String()))
+			for _, line := range p.diagnosis.Evidence() {
+				c.printf("  %s\n", line)
+			}
+			return ResultNothingToDo, nil
+		}
+
+		c.failure(fmt.Sprintf("%s is broken", pr.String()))
+		c.printDiagnosis(*p.rebase, "  ")
+		c.printf("  new base: %s\n", color.Cyan(p.rebase.NewBase))
+		return ResultBroken, nil
+	}
+	return ResultFailed, fmt.Errorf("#%d is not one of your open PRs", c.cfg.PRNumber)
+}
@@ -1,17 +1,78 @@
 package domino
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"io"
 	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/134130/gh-domino/internal/notify"
+	"github.com/134130/gh-domino/internal/progress"
+	"github.com/134130/gh-domino/internal/stackedpr"
+)
+
+const (
+	// CommandCheck only reports the broken PRs, and fails if there are any.
+	CommandCheck = "check"
+	// CommandExplain reports why a PR is broken or not, with the evidence the decision was based on.
+	CommandExplain = "explain"
+	// CommandServe listens for the webhooks of the merged PRs, and cascades their children.
+	CommandServe = "serve"
+	// CommandWatch polls the PRs, and cascades the children of the merged ones or tells how to.
+	CommandWatch = "watch"
+	// CommandAction cascades the children of the PR whose merge triggered the GitHub Actions workflow.
+	CommandAction = "action"
 )
 
 type Config struct {
-	Auto   bool
-	DryRun bool
+	// Command is the subcommand to run, or empty to rebase the broken PRs.
+	Command string
+	// PRNumber is the PR to explain.
+	PRNumber int
+	Auto     *bool
+	DryRun   *bool
+	Atomic   *bool
+	// PredictConflicts simulates each rebase in a dry run to tell whether it would conflict.
+	PredictConflicts *bool
+	// Interactive shows the rebase plan in a TUI to review and adjust it before executing it.
+	Interactive *bool
+	// CI prints plain lines without colors nor prompts, and makes the exit code tell how the run ended.
+	CI *bool
+	// Verbose shows why each PR is considered broken, with the evidence the decision was based on.
+	Verbose *bool
+	// Exec is the command to run on each rebased PR before pushing it, which stops the cascade if it fails.
+	Exec string
+	// ExecEachCommit runs Exec on each commit of the rebased PR rather than only at its tip.
+	ExecEachCommit *bool
+	// MergedPRs restricts the run to the stacks which were based on these merged PRs, when set.
+	MergedPRs []int
+	// Addr is the address to listen on with CommandServe.
+	Addr string
+	// Interval is how often the PRs are polled with CommandWatch.
+	Interval time.Duration
+	// Repo is the repository to operate on as [HOST/]OWNER/NAME, from a mirror rather than the working directory.
+	Repo string
+	// Cache keeps the PR metadata in .git/domino/cache between runs.
+	Cache  *bool
 	DumpTo string
 	Writer io.Writer
+	// Reader is where the interactive view reads the keys from, the terminal when nil.
+	Reader io.Reader
+	// Observer also receives the events of the run when set, such as to summarize it.
+	Observer progress.Reporter
+
+	// Settings come from the settings files, and are overridden by the flags.
+	Settings
+	// Flags are the settings given as flags, which also override the settings read again from the mirror
+	// of the repository when --repo is set.
+	Flags Settings
+	// FallbackAuthor is the author of the PRs to handle when neither the flags nor the settings tell,
+	// such as the author of the PR merged in a webhook.
+	FallbackAuthor string
 }
 
 func ParseConfig() (Config, error) {
@@ -19,15 +80,253 @@ func ParseConfig() (Config, error) {
 		Writer: os.Stdout,
 	}
 
-	flag.BoolVar(&c.Auto, "auto", false, "Enable auto mode to rebase with confirmation")
-	flag.BoolVar(&c.DryRun, "dry-run", false, "Don't rebase the changes")
+	settings, err := LoadSettings(context.Background())
+	if err != nil {
+		return c, err
+	}
+	c.Settings = settings
+
+	c.Auto = flag.Bool("auto", false, "Enable auto mode to rebase with confirmation")
+	c.DryRun = flag.Bool("dry-run", false, "Don't rebase the changes")
+	c.Atomic = flag.Bool("atomic", false, "Push all rebased branches at once after the whole cascade succeeded")
+	c.PredictConflicts = flag.Bool("predict-conflicts", false, "In dry run mode, simulate each rebase to tell whether it would conflict")
+	c.Interactive = flag.Bool("interactive", false, "Review and adjust the rebase plan in an interactive view before executing it")
+	c.CI = flag.Bool("ci", false, "Print plain lines without colors nor prompts, and exit with a code telling how the run ended")
+	c.Verbose = flag.Bool("verbose", false, "Show why each PR is considered broken")
+	flag.StringVar(&c.Exec, "exec", "", "Command to run on each rebased branch before pushing it, which stops the cascade if it fails")
+	c.ExecEachCommit = flag.Bool("exec-each-commit", false, "With --exec, run the command on each commit of the rebased branches rather than only at their tip")
+	noCache := flag.Bool("no-cache", false, "Fetch the PR metadata again rather than reusing the one cached by the previous runs")
+	flag.StringVar(&c.Addr, "addr", ":8080", "With serve, address to listen on for the webhooks")
+	flag.DurationVar(&c.Interval, "interval", defaultWatchInterval, "With watch, how often to poll the PRs")
 	flag.StringVar(&c.DumpTo, "dump-to", "", "Dump git commands to a file for testing purposes")
+	flag.StringVar(&c.Repo, "repo", "", "Operate on this repository, as [HOST/]OWNER/NAME, from a cached mirror rather than the current directory")
+	flag.StringVar(&c.Remote, "remote", c.Remote, "Remote the PRs are pushed to, origin if unset")
+	flag.StringVar(&c.DefaultBranch, "default-branch", c.DefaultBranch, "Default branch of the remote, told by the remote if unset")
+	flag.StringVar(&c.Author, "author", c.Author, "Only handle the PRs of this author, @me if unset")
+	flag.StringVar(&c.DeleteBranches, "delete-branches", c.DeleteBranches, "Which branches to delete: never (if unset) or closed")
+	c.UpdateDescriptions = flag.Bool("update-descriptions", c.IsUpdateDescriptions(), "Note in the description of a retargeted PR which base it was moved from")
+	var pushOptions []string
+	flag.Func("push-option", "Option to send to the remote with each push, can be repeated", func(s string) error {
+		pushOptions = append(pushOptions, s)
+		return nil
+	})
+	var skipLabels []string
+	flag.Func("skip-label", "Never rewrite the PRs with this label, can be repeated", func(s string) error {
+		skipLabels = append(skipLabels, s)
+		return nil
+	})
+	var trunkBranches []string
+	flag.Func("trunk", "Long-lived branch the stacks may be based on besides the default branch, such as release/*, can be repeated", func(s string) error {
+		trunkBranches = append(trunkBranches, s)
+		return nil
+	})
+	flag.StringVar(&c.SkipState, "skip-state", c.SkipState, "Never rewrite the PRs in this state: draft or ready")
+
+	args := os.Args[1:]
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		c.Command, args = args[0], args[1:]
+	}
+	_ = flag.CommandLine.Parse(args)
+	cache := !*noCache && c.DumpTo == ""
+	c.Cache = &cache
+	if pushOptions != nil {
+		c.PushOptions = pushOptions
+	}
+	if skipLabels != nil {
+		c.SkipLabels = skipLabels
+	}
+	if trunkBranches != nil {
+		c.TrunkBranches = trunkBranches
+	}
+	flag.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "remote":
+			c.Flags.Remote = c.Remote
+		case "default-branch":
+			c.Flags.DefaultBranch = c.DefaultBranch
+		case "author":
+			c.Flags.Author = c.Author
+		case "delete-branches":
+			c.Flags.DeleteBranches = c.DeleteBranches
+		case "update-descriptions":
+			c.Flags.UpdateDescriptions = c.UpdateDescriptions
+		case "skip-state":
+			c.Flags.SkipState = c.SkipState
+		}
+	})
+	c.Flags.PushOptions = pushOptions
+	c.Flags.SkipLabels = skipLabels
+	c.Flags.TrunkBranches = trunkBranches
+	if err := c.Settings.validate(); err != nil {
+		return c, err
+	}
 
-	flag.Parse()
+	switch c.Command {
+	case "", CommandCheck, CommandServe, CommandWatch, CommandAction:
+		if flag.NArg() > 0 {
+			return c, fmt.Errorf("unexpected arguments: %s", strings.Join(flag.Args(), " "))
+		}
+	case CommandExplain:
+		if flag.NArg() != 1 {
+			return c, fmt.Errorf("usage: gh domino explain <pr>")
+		}
+		number, err := strconv.Atoi(strings.TrimPrefix(flag.Arg(0), "#"))
+		if err != nil {
+			return c, fmt.Errorf("invalid PR number %q", flag.Arg(0))
+		}
+		c.PRNumber = number
+	default:
+		return c, fmt.Errorf("unknown command %q", c.Command)
+	}
+	if c.IsWatch() && (c.IsDryRun() || c.IsInteractive()) {
+		return c, fmt.Errorf("cannot use watch with --dry-run or --interactive")
+	}
+	if c.Command != "" && !c.IsWatch() && (c.IsAuto() || c.IsDryRun() || c.IsInteractive()) {
+		return c, fmt.Errorf("cannot use %s with --auto, --dry-run or --interactive", c.Command)
+	}
+	if c.IsWatch() && c.Interval <= 0 {
+		return c, fmt.Errorf("--interval must be positive")
+	}
+
+	if c.IsServe() && c.Repo != "" {
+		return c, fmt.Errorf("cannot use serve with --repo, the repository is told by each webhook")
+	}
+	if c.IsAction() && c.Repo != "" {
+		return c, fmt.Errorf("cannot use action with --repo, the repository is the one checked out by the workflow")
+	}
 
-	if c.Auto && c.DryRun {
+	if c.IsAuto() && c.IsDryRun() {
 		return c, fmt.Errorf("cannot use --auto and --dry-run together")
 	}
+	if c.IsInteractive() && (c.IsAuto() || c.IsDryRun()) {
+		return c, fmt.Errorf("cannot use --interactive with --auto or --dry-run")
+	}
+	if c.IsCI() && c.IsInteractive() {
+		return c, fmt.Errorf("cannot use --ci with --interactive")
+	}
+	if c.IsExecEachCommit() && c.Exec == "" {
+		return c, fmt.Errorf("--exec-each-commit can only be used with --exec")
+	}
+	if c.IsPredictConflicts() && !c.IsDryRun() {
+		return c, fmt.Errorf("--predict-conflicts can only be used with --dry-run")
+	}
 
 	return c, nil
 }
+
+// IsAuto tells whether to go on without confirmation, which CI mode implies when it changes anything.
+func (c Config) IsAuto() bool {
+	return c.Auto != nil && *c.Auto || c.IsCI() && !c.IsDryRun() && c.Command == ""
+}
+
+func (c Config) IsDryRun() bool {
+	return c.DryRun != nil && *c.DryRun
+}
+
+func (c Config) IsAtomic() bool {
+	return c.Atomic != nil && *c.Atomic
+}
+
+func (c Config) IsPredictConflicts() bool {
+	return c.PredictConflicts != nil && *c.PredictConflicts
+}
+
+func (c Config) IsInteractive() bool {
+	return c.Interactive != nil && *c.Interactive
+}
+
+func (c Config) IsCI() bool {
+	return c.CI != nil && *c.CI
+}
+
+func (c Config) IsCheck() bool {
+	return c.Command == CommandCheck
+}
+
+func (c Config) IsExplain() bool {
+	return c.Command == CommandExplain
+}
+
+func (c Config) IsServe() bool {
+	return c.Command == CommandServe
+}
+
+func (c Config) IsWatch() bool {
+	return c.Command == CommandWatch
+}
+
+// WatchInterval returns how often the PRs are polled with CommandWatch.
+func (c Config) WatchInterval() time.Duration {
+	if c.Interval <= 0 {
+		return defaultWatchInterval
+	}
+	return c.Interval
+}
+
+func (c Config) IsAction() bool {
+	return c.Command == CommandAction
+}
+
+func (c Config) IsExecEachCommit() bool {
+	return c.ExecEachCommit != nil && *c.ExecEachCommit
+}
+
+func (c Config) IsCache() bool {
+	return c.Cache != nil && *c.Cache
+}
+
+func (c Config) IsVerbose() bool {
+	return c.Verbose != nil && *c.Verbose
+}
+
+// RemoteName returns the remote the PRs are pushed to.
+func (c Config) RemoteName() string {
+	if c.Remote == "" {
+		return "origin"
+	}
+	return c.Remote
+}
+
+// AuthorFilter returns the author of the PRs to handle.
+func (c Config) AuthorFilter() string {
+	switch {
+	case c.Author != "":
+		return c.Author
+	case c.FallbackAuthor != "":
+		return c.FallbackAuthor
+	}
+	return "@me"
+}
+
+// SkipRules returns which PRs must never be rewritten.
+func (c Config) SkipRules() stackedpr.SkipRules {
+	return stackedpr.SkipRules{
+		Labels:            c.SkipLabels,
+		State:             c.SkipState,
+		ProtectedBranches: c.ProtectedBranches,
+	}
+}
+
+// NotificationSinks returns where to tell about the broken PRs whose rebase stopped on conflicts.
+// The terminal notifications are only sent when there is a terminal to show them.
+func (c Config) NotificationSinks() []notify.Sink {
+	var sinks []notify.Sink
+	if c.Notify.Terminal != "" && progress.IsTerminal(os.Stderr) {
+		sinks = append(sinks, notify.NewTerminal(os.Stderr, c.Notify.Terminal))
+	}
+	if c.Notify.Webhook != "" {
+		sinks = append(sinks, notify.NewWebhook(c.Notify.Webhook))
+	}
+	if c.Notify.Command != "" {
+		sinks = append(sinks, notify.NewCommand(c.Notify.Command))
+	}
+	return sinks
+}
+
+func (c Config) IsUpdateDescriptions() bool {
+	return c.UpdateDescriptions != nil && *c.UpdateDescriptions
+}
+
+func (c Config) IsDeleteClosedBranches() bool {
+	return c.DeleteBranches == DeleteBranchesClosed
+}
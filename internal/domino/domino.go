@@ -4,182 +4,393 @@ import (
 	"context"
 	"errors"
 	"fmt"
-
-	tea "github.com/charmbracelet/bubbletea"
+	"maps"
+	"slices"
+	"strings"
 
 	"github.com/134130/gh-domino/git"
 	"github.com/134130/gh-domino/gitobj"
 	"github.com/134130/gh-domino/internal/color"
-	"github.com/134130/gh-domino/internal/ui"
+	"github.com/134130/gh-domino/internal/notify"
+	"github.com/134130/gh-domino/internal/parallel"
+	"github.com/134130/gh-domino/internal/progress"
+	"github.com/134130/gh-domino/internal/queue"
 
-	"github.com/134130/gh-domino/internal/spinner"
 	"github.com/134130/gh-domino/internal/stackedpr"
 	"github.com/134130/gh-domino/internal/util"
 )
 
-var write = func(msg string, args ...interface{}) {}
-
-func success(msg string) {
-	write("%s %s\n", color.Green("✔"), msg)
+// cascade holds the state shared while walking the dependency trees.
+type cascade struct {
+	cfg                Config
+	progress           progress.Reporter
+	prMap              map[string]gitobj.PullRequest
+	mergedPRs          []gitobj.PullRequest
+	mergedPRsByHeadRef map[string]gitobj.PullRequest
+	// prHeadShas holds the SHA of each PR head on the remote as of the fetch,
+	// and is updated once a branch has been pushed.
+	prHeadShas   map[string]string
+	processedPRs map[int]bool
+	// rebasedLocally holds the branches rebased in this run but not pushed yet.
+	rebasedLocally map[string]bool
+	// planned holds the branches that would be rebased in a dry run or while planning.
+	planned map[string]bool
+	// pendingPushes holds the rebased PRs to push at once in atomic mode.
+	pendingPushes *queue.Queue[stackedpr.RebaseInfo]
+	// predictions holds the simulated rebase of each branch in a dry run.
+	predictions map[string]stackedpr.RebasePrediction
+	// closedPRs holds the PRs closed in this run because they were empty after the rebase.
+	closedPRs map[string]stackedpr.RebaseInfo
+	// pushedPRs holds the numbers of the PRs pushed in this run.
+	pushedPRs map[int]bool
+	// skippedPRs counts the broken PRs left as is because of the skip rules.
+	skippedPRs int
+	// rootStates holds the state of the PRs not stacked on another open PR, determined before the run.
+	rootStates map[int]prState
 }
 
-func failure(msg string) {
-	write("%s %s\n", color.Red("✘"), msg)
+// prState is what determinePRState returns for a PR.
+type prState struct {
+	diagnosis stackedpr.Diagnosis
+	newBase   string
+	upstream  string
+	err       error
 }
 
 func Run(ctx context.Context, cfg Config) error {
-	write = func(msg string, args ...interface{}) {
-		_, _ = fmt.Fprintf(cfg.Writer, msg, args...)
-	}
+	_, err := RunWithResult(ctx, cfg)
+	return err
+}
 
+// RunWithResult runs the cascade like Run, and tells how it ended.
+func RunWithResult(ctx context.Context, cfg Config) (Result, error) {
 	var err error
 	if cfg.DumpTo != "" {
 		git.CommandRunner, err = git.NewLoggingRunner(cfg.DumpTo)
 		if err != nil {
-			return fmt.Errorf("failed to create logging runner: %w", err)
+			return ResultFailed, fmt.Errorf("failed to create logging runner: %w", err)
 		}
 	}
 
 	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var r progress.Reporter
+	if cfg.IsCI() {
+		defer color.Disable()()
+		r = progress.NewPlain(cfg.Writer)
+	} else {
+		r = progress.New(ctx, cancel, cfg.Writer)
+	}
+	if cfg.Observer != nil {
+		r = progress.Tee(r, cfg.Observer)
+	}
+	defer r.Close()
 
-	m := ui.NewModel(ctx, cancel)
-	p := tea.NewProgram(m, tea.WithOutput(cfg.Writer))
+	cfg, err = prepareRun(ctx, cfg)
+	if err != nil {
+		return ResultFailed, err
+	}
+	s, err := fetchStacks(ctx, cfg, r)
+	if err != nil {
+		return ResultFailed, err
+	}
+	return runStacks(ctx, cancel, cfg, r, s)
+}
 
-	go func() {
-		if _, err := p.Run(); err != nil {
-			cancel()
-			failure(fmt.Sprintf("Failed to start UI: %v", err))
+// prepareRun sets up the state of the git package for the repository to operate on. With --repo, it returns
+// the configuration with the settings of that repository, as the ones read before were of the working directory.
+func prepareRun(ctx context.Context, cfg Config) (Config, error) {
+	git.Remote = cfg.RemoteName()
+	if cfg.Repo != "" {
+		if err := useMirror(ctx, cfg.Repo); err != nil {
+			return cfg, err
+		}
+		settings, err := LoadSettings(ctx)
+		if err != nil {
+			return cfg, err
 		}
-	}()
+		// The mirror keeps the remote it was created with, whatever the repository calls it.
+		settings.Remote = cfg.Remote
+		cfg.Settings = settings.Merge(cfg.Flags)
+	}
+	git.UseDefaultBranch(cfg.DefaultBranch)
+	git.MetadataCache = nil
+	if cfg.IsCache() {
+		// Without the cache, everything is fetched as before.
+		if cache, err := git.OpenCache(ctx); err == nil {
+			git.MetadataCache = cache
+		}
+	}
+	return cfg, nil
+}
 
-	defer func() {
-		p.Quit()
-		p.Wait()
-	}()
+// stacks holds the PRs fetched from the remote, and the dependency trees they make.
+type stacks struct {
+	prs        []gitobj.PullRequest
+	mergedPRs  []gitobj.PullRequest
+	prHeadShas map[string]string
+	roots      []*stackedpr.Node
+}
 
-	lw := ui.NewLogWriter(m, p)
-	_, _ = lw.WriteString("git fetch origin")
-	if err := git.Fetch(ctx, "origin", git.WithStdout(lw)); err != nil {
-		return fmt.Errorf("failed to fetch origin: %s", err)
+// fetchStacks fetches the remote and the PRs of the author, and builds their dependency trees.
+func fetchStacks(ctx context.Context, cfg Config, r progress.Reporter) (*stacks, error) {
+	author := cfg.AuthorFilter()
+	lw := progress.NewLogWriter(r)
+	r.Report(progress.FetchStarted{})
+	_, _ = fmt.Fprintf(lw, "git fetch %s", git.Remote)
+	if err := git.Fetch(ctx, git.Remote, git.WithStdout(lw)); err != nil {
+		r.Report(progress.FetchFailed{Err: err})
+		return nil, fmt.Errorf("failed to fetch %s: %s", git.Remote, err)
 	}
 
-	_, _ = lw.WriteString("gh pr list --author @me")
-	prs, err := git.ListPullRequests(ctx)
+	_, _ = fmt.Fprintf(lw, "gh pr list --author %s", author)
+	prs, err := git.ListPullRequests(ctx, author)
 	if err != nil {
-		return fmt.Errorf("failed to list pull requests: %s", err)
+		r.Report(progress.FetchFailed{Err: err})
+		return nil, fmt.Errorf("failed to list pull requests: %s", err)
 	}
 
-	_, _ = lw.WriteString("gh pr list --author @me --state merged --search sort:updated")
-	mergedPRs, err := git.ListMergedPullRequests(ctx)
+	_, _ = fmt.Fprintf(lw, "gh pr list --author %s --state merged --search sort:updated", author)
+	mergedPRs, err := git.ListMergedPullRequests(ctx, author)
 	if err != nil {
-		return fmt.Errorf("failed to list merged pull requests: %s", err)
+		r.Report(progress.FetchFailed{Err: err})
+		return nil, fmt.Errorf("failed to list merged pull requests: %s", err)
 	}
 
-	prHeadShas := make(map[string]string)
 	for _, pr := range prs {
-		_, _ = fmt.Fprintf(lw, "git rev-parse origin/%s", pr.HeadRefName)
-		sha, err := git.RevParse(ctx, "origin/"+pr.HeadRefName)
+		_, _ = fmt.Fprintf(lw, "git rev-parse %s", git.RemoteRef(pr.HeadRefName))
+	}
+	shas, err := parallel.Map(ctx, prs, parallel.DefaultLimit, func(ctx context.Context, pr gitobj.PullRequest) (string, error) {
+		sha, err := git.RevParse(ctx, git.RemoteRef(pr.HeadRefName))
 		if err != nil {
-			write("Could not get SHA for %s: %v\n", pr.HeadRefName, err)
-			return fmt.Errorf("could not get SHA for %s: %w", pr.HeadRefName, err)
+			return "", fmt.Errorf("could not get SHA for %s: %w", pr.HeadRefName, err)
 		}
-		prHeadShas[pr.HeadRefName] = sha
+		return sha, nil
+	})
+	if err != nil {
+		r.Report(progress.FetchFailed{Err: err})
+		return nil, err
+	}
+	prHeadShas := make(map[string]string)
+	for i, pr := range prs {
+		prHeadShas[pr.HeadRefName] = shas[i]
 	}
 
-	roots, err := stackedpr.BuildDependencyTree(ctx, prs, mergedPRs, prHeadShas)
+	roots, err := stackedpr.BuildDependencyTree(ctx, prs, mergedPRs, prHeadShas, cfg.TrunkBranches)
 	if err != nil {
-		return err
+		r.Report(progress.FetchFailed{Err: err})
+		return nil, err
+	}
+	stackedpr.MarkSkipped(roots, cfg.SkipRules())
+	stackedpr.MarkTrunks(roots, cfg.TrunkBranches)
+	r.Report(progress.FetchDone{})
+	return &stacks{prs: prs, mergedPRs: mergedPRs, prHeadShas: prHeadShas, roots: roots}, nil
+}
+
+// runStacks runs the command of the configuration on the fetched stacks.
+func runStacks(ctx context.Context, cancel context.CancelFunc, cfg Config, r progress.Reporter, s *stacks) (Result, error) {
+	roots := s.roots
+	if len(cfg.MergedPRs) > 0 {
+		roots = stackedpr.ChildrenOf(roots, cfg.MergedPRs)
 	}
 
-	p.Send(ui.DoneMsg{})
-	p.Wait()
-	success("Fetching pull requests...")
+	c := &cascade{
+		cfg:                cfg,
+		progress:           r,
+		prMap:              make(map[string]gitobj.PullRequest),
+		mergedPRs:          s.mergedPRs,
+		mergedPRsByHeadRef: make(map[string]gitobj.PullRequest),
+		prHeadShas:         s.prHeadShas,
+		processedPRs:       make(map[int]bool),
+		rebasedLocally:     make(map[string]bool),
+		planned:            make(map[string]bool),
+		pendingPushes:      queue.New[stackedpr.RebaseInfo](),
+		predictions:        make(map[string]stackedpr.RebasePrediction),
+		closedPRs:          make(map[string]stackedpr.RebaseInfo),
+		pushedPRs:          make(map[int]bool),
+	}
+	for _, pr := range s.prs {
+		c.prMap[pr.HeadRefName] = pr
+	}
+	for _, pr := range s.mergedPRs {
+		c.mergedPRsByHeadRef[pr.HeadRefName] = pr
+	}
 
-	write("\n")
-	write(stackedpr.RenderDependencyTree(roots))
-	write("\n\n")
+	c.diagnoseRoots(ctx, roots)
 
-	if cfg.DryRun {
-		write("Dry run mode enabled. The following PRs would be rebased:\n")
+	c.printf("\n")
+	if cfg.IsCheck() {
+		return c.runCheck(ctx, roots)
+	}
+	if cfg.IsExplain() {
+		return c.runExplain(ctx, roots)
 	}
 
-	prMap := make(map[string]gitobj.PullRequest)
-	for _, pr := range prs {
-		prMap[pr.HeadRefName] = pr
+	var result Result
+	var err error
+	if cfg.IsInteractive() {
+		result, err = c.runInteractive(ctx, cancel, roots)
+	} else {
+		result, err = c.runCascade(ctx, roots)
 	}
-	mergedPRsByHeadRef := make(map[string]gitobj.PullRequest)
-	for _, pr := range mergedPRs {
-		mergedPRsByHeadRef[pr.HeadRefName] = pr
+	if err != nil || cfg.IsDryRun() {
+		return result, err
 	}
+	c.deleteClosedBranches(ctx)
+	return c.runPostCascadeHook(ctx, result), nil
+}
+
+// runCascade rebases the broken PRs of the dependency trees, asking for confirmation unless in auto mode.
+func (c *cascade) runCascade(ctx context.Context, roots []*stackedpr.Node) (Result, error) {
+	cfg := c.cfg
+	c.printf("%s", stackedpr.RenderDependencyTree(roots))
+	c.printf("\n\n")
 
-	processedPRs := make(map[int]bool)
+	if cfg.IsDryRun() {
+		c.printf("Dry run mode enabled. The following PRs would be rebased:\n")
+	}
 
 	totalProcessed := 0
 	for _, root := range roots {
-		processed, err := processDependencyTree(ctx, root, prMap, mergedPRsByHeadRef, prHeadShas, cfg, mergedPRs, processedPRs)
+		processed, err := c.processDependencyTree(ctx, root)
 		if err != nil {
-			var errRebaseConflict *ErrRebaseConflict
-			if errors.As(err, &errRebaseConflict) {
-				failure(fmt.Sprintf(`Failed to handle broken PR %s due to rebase conflicts.
-  Please resolve the conflicts manually and re-run the tool if needed.
-  You can use the following command to rebase manually:
-      %s`, errRebaseConflict.BrokenPR.PR.PRNumberString(), errRebaseConflict.Command()))
-			} else {
-				failure(err.Error())
-			}
-			return nil
+			return c.reportFailure(ctx, err), nil
 		}
 		totalProcessed += processed
 	}
 
+	if err := c.pushPending(ctx); err != nil {
+		c.failure(err.Error())
+		return ResultFailed, nil
+	}
+
+	if totalProcessed == 0 && c.skippedPRs > 0 {
+		c.success("No broken PRs to rebase apart from the skipped ones.")
+		return ResultNothingToDo, nil
+	}
 	if totalProcessed == 0 {
-		success("No broken PRs found.")
+		c.success("No broken PRs found.")
+		return ResultNothingToDo, nil
 	}
+	for _, prediction := range c.predictions {
+		if prediction.Outcome == stackedpr.RebaseOutcomeConflict {
+			return ResultConflict, nil
+		}
+	}
+	return ResultRebased, nil
+}
 
-	return nil
+func (c *cascade) printf(format string, args ...interface{}) {
+	c.progress.Report(progress.Info{Text: fmt.Sprintf(format, args...)})
+}
+
+func (c *cascade) success(msg string) {
+	c.progress.Report(progress.Success{Text: msg})
+}
+
+func (c *cascade) failure(msg string) {
+	c.progress.Report(progress.Failure{Text: msg})
+}
+
+// printDiagnosis prints why the PR is broken, with the evidence the decision was based on.
+func (c *cascade) printDiagnosis(brokenPR stackedpr.RebaseInfo, indent string) {
+	c.printf("%sreason: %s\n", indent, brokenPR.Diagnosis.Describe(brokenPR))
+	for _, line := range brokenPR.Diagnosis.Evidence() {
+		c.printf("%s%s\n", indent, line)
+	}
+	if brokenPR.Upstream != "" {
+		c.printf("%supstream: %s\n", indent, brokenPR.Upstream)
+	}
+}
+
+// reportFailure reports the error that stopped the cascade, with the command to
+// continue manually on a rebase conflict, which is also notified, and returns the result of the run.
+func (c *cascade) reportFailure(ctx context.Context, err error) Result {
+	result := ResultFailed
+	var errRebaseConflict *ErrRebaseConflict
+	if errors.As(err, &errRebaseConflict) {
+		result = ResultConflict
+		c.failure(fmt.Sprintf(`Failed to handle broken PR %s due to rebase conflicts.
+  Please resolve the conflicts manually and re-run the tool if needed.
+  You can use the following command to rebase manually:
+      %s`, errRebaseConflict.BrokenPR.PR.PRNumberString(), errRebaseConflict.Command()))
+		c.notifyConflict(ctx, errRebaseConflict)
+	} else {
+		c.failure(err.Error())
+	}
+	if !c.pendingPushes.IsEmpty() {
+		c.printf("  Nothing has been pushed. The rebased branches are left as is in your local repository.\n")
+	}
+	return result
+}
+
+// notifyConflict tells the notification sinks about the PR whose rebase stopped on conflicts.
+func (c *cascade) notifyConflict(ctx context.Context, conflict *ErrRebaseConflict) {
+	pr := conflict.BrokenPR.PR
+	n := notify.Notification{
+		Event:      notify.EventRebaseConflict,
+		PR:         pr.Number,
+		Title:      pr.Title,
+		URL:        pr.Url,
+		HeadBranch: pr.HeadRefName,
+		BaseBranch: pr.BaseRefName,
+		NewBase:    conflict.BrokenPR.NewBase,
+		Command:    conflict.PlainCommand(),
+	}
+	for _, sink := range c.cfg.NotificationSinks() {
+		if err := sink.Notify(ctx, n); err != nil {
+			c.failure(fmt.Sprintf("Failed to notify about %s: %v", pr.PRNumberString(), err))
+		}
+	}
 }
 
 // processDependencyTree recursively traverses the dependency tree and handles broken PRs.
-func processDependencyTree(
-	ctx context.Context,
-	node *stackedpr.Node,
-	prMap map[string]gitobj.PullRequest,
-	mergedPRsByHeadRef map[string]gitobj.PullRequest,
-	prHeadShas map[string]string,
-	cfg Config,
-	mergedPRs []gitobj.PullRequest,
-	processedPRs map[int]bool,
-) (int, error) {
+func (c *cascade) processDependencyTree(ctx context.Context, node *stackedpr.Node) (int, error) {
 	if node == nil {
 		return 0, nil
 	}
 	pr := node.Value
 
 	// Skip already processed PRs
-	if processedPRs[pr.Number] {
+	if c.processedPRs[pr.Number] {
 		return 0, nil
 	}
-	processedPRs[pr.Number] = true
+	c.processedPRs[pr.Number] = true
 
 	totalProcessed := 0
 
-	isBroken, newBase, upstream, err := determinePRState(ctx, pr, prMap, mergedPRsByHeadRef, prHeadShas, cfg, mergedPRs)
+	diagnosis, newBase, upstream, err := c.determinePRState(ctx, pr)
 	if err != nil {
-		write("Error determining state for PR %s: %v\n", pr.PRNumberString(), err)
+		c.printf("Error determining state for PR %s: %v\n", pr.PRNumberString(), err)
 		// Continue to children even if parent has an error
-	} else if isBroken {
+	} else if diagnosis.IsBroken() && node.SkipReason != "" {
+		// The PR stays as is, and so do its children stacked on it, unless they are broken on their own.
+		if c.cfg.IsDryRun() {
+			c.printf("  %s skipped (%s)\n", pr.String(), node.SkipReason)
+		} else {
+			c.printf("Skipping %s (%s)\n", pr.String(), node.SkipReason)
+		}
+		c.skippedPRs++
+	} else if diagnosis.IsBroken() {
 		if newBase == "" {
 			newBase = pr.BaseRefName
 		}
-		brokenPR := stackedpr.RebaseInfo{PR: pr, NewBase: newBase, Upstream: upstream}
-		if err := handleBrokenPR(ctx, brokenPR, cfg, prHeadShas); err != nil {
+		brokenPR := stackedpr.RebaseInfo{
+			PR:        pr,
+			NewBase:   newBase,
+			Upstream:  upstream,
+			Diagnosis: diagnosis,
+			OntoLocal: c.rebasedLocally[newBase],
+		}
+		if err := c.handleBrokenPR(ctx, brokenPR); err != nil {
 			return totalProcessed, err
 		}
 		totalProcessed++
 	}
 
 	for _, child := range node.Children {
-		processed, err := processDependencyTree(ctx, child, prMap, mergedPRsByHeadRef, prHeadShas, cfg, mergedPRs, processedPRs)
+		processed, err := c.processDependencyTree(ctx, child)
 		if err != nil {
 			return totalProcessed, err
 		}
@@ -188,140 +399,208 @@ func processDependencyTree(
 	return totalProcessed, nil
 }
 
+// diagnoseRoots determines the state of the PRs not stacked on another open PR concurrently, as it only depends
+// on what was fetched and not on the PRs handled before them in the run. determinePRState then returns it
+// when the run gets to them, so the results are consumed in the order of the dependency trees as before.
+func (c *cascade) diagnoseRoots(ctx context.Context, roots []*stackedpr.Node) {
+	var prs []gitobj.PullRequest
+	seen := make(map[int]bool)
+	var walk func(nodes []*stackedpr.Node)
+	walk = func(nodes []*stackedpr.Node) {
+		for _, node := range nodes {
+			if _, isStackedPR := c.prMap[node.Value.BaseRefName]; !isStackedPR && !seen[node.Value.Number] {
+				seen[node.Value.Number] = true
+				prs = append(prs, node.Value)
+			}
+			walk(node.Children)
+		}
+	}
+	walk(roots)
+
+	states, _ := parallel.Map(ctx, prs, parallel.DefaultLimit, func(ctx context.Context, pr gitobj.PullRequest) (prState, error) {
+		diagnosis, newBase, upstream, err := c.determinePRState(ctx, pr)
+		return prState{diagnosis: diagnosis, newBase: newBase, upstream: upstream, err: err}, nil
+	})
+	c.rootStates = make(map[int]prState, len(prs))
+	for i, pr := range prs {
+		c.rootStates[pr.Number] = states[i]
+	}
+}
+
 // determinePRState checks if a pull request is "broken" and needs to be rebased.
 // A PR is considered broken if:
-// 1. Its base branch corresponds to a PR that has already been merged.
-// 2. In a dry run, its parent PR has been marked for rebase.
-// 3. It has diverged from the default branch, containing commits from another merged PR.
-// It returns whether the PR is broken and what its new base branch ('onto') should be.
-func determinePRState(
-	ctx context.Context,
-	pr gitobj.PullRequest,
-	prMap map[string]gitobj.PullRequest,
-	mergedPRsByHeadRef map[string]gitobj.PullRequest,
-	prHeadShas map[string]string,
-	cfg Config,
-	mergedPRs []gitobj.PullRequest,
-) (isBroken bool, newBase string, upstream string, err error) {
+// 1. Its parent PR has been closed earlier in this run because it was empty after the rebase.
+// 2. Its base branch corresponds to a PR that has already been merged.
+// 3. Its parent PR has been rebased earlier in this run (in a dry run, or pending an atomic push).
+// 4. It has diverged from the default branch, containing commits from another merged PR.
+// It returns the diagnosis of the PR along with its evidence, and what its new base branch ('onto') should be.
+func (c *cascade) determinePRState(ctx context.Context, pr gitobj.PullRequest) (diagnosis stackedpr.Diagnosis, newBase string, upstream string, err error) {
+	if state, ok := c.rootStates[pr.Number]; ok {
+		return state.diagnosis, state.newBase, state.upstream, state.err
+	}
+
+	// --- Check 0: Was the parent PR closed as empty? ---
+	// Its commits are already in its new base, so this PR takes its place on that base.
+	if closedPR, ok := c.closedPRs[pr.BaseRefName]; ok {
+		diagnosis.Reason = stackedpr.BrokenReasonBaseClosed
+		return diagnosis, closedPR.NewBase, c.prHeadShas[closedPR.PR.HeadRefName], nil
+	}
+
 	// --- Check 1: Is the base a merged PR? ---
 	// This applies only to root PRs in a stack.
-	if _, isStackedPR := prMap[pr.BaseRefName]; !isStackedPR {
-		if mergedBasePR, isMerged := mergedPRsByHeadRef[pr.BaseRefName]; isMerged {
-			if mergedBasePR.MergeCommit.Sha != "" && len(mergedBasePR.Commits) > 0 {
-				lastCommit := mergedBasePR.Commits[len(mergedBasePR.Commits)-1].Oid
-				isAncestor, err := git.IsAncestor(ctx, lastCommit, mergedBasePR.MergeCommit.Sha)
+	if _, isStackedPR := c.prMap[pr.BaseRefName]; !isStackedPR {
+		if mergedBasePR, isMerged := c.mergedPRsByHeadRef[pr.BaseRefName]; isMerged {
+			strategy, upstream, err := stackedpr.DetectMergeStrategy(ctx, mergedBasePR)
+			if err != nil {
+				return stackedpr.Diagnosis{}, "", "", fmt.Errorf("failed to detect how %s was merged: %v", mergedBasePR.PRNumberString(), err)
+			}
+			if strategy == stackedpr.MergeStrategyRebaseMerge {
+				// The PR may be based on another version of its base than the merged one,
+				// so find where its own commits start.
+				rebasedUpstream, err := stackedpr.FindRebasedUpstream(ctx, mergedBasePR, c.prHeadShas[pr.HeadRefName])
 				if err != nil {
-					return false, "", "", fmt.Errorf("failed to check ancestry: %v", err)
+					return stackedpr.Diagnosis{}, "", "", fmt.Errorf("failed to match the commits of %s with %s: %v", pr.PRNumberString(), mergedBasePR.PRNumberString(), err)
 				}
-				if !isAncestor {
-					// Squash merge detected
-					return true, mergedBasePR.BaseRefName, lastCommit, nil
+				if rebasedUpstream != "" {
+					upstream = rebasedUpstream
 				}
 			}
-			return true, mergedBasePR.BaseRefName, "", nil
+			diagnosis.MergedPR = &mergedBasePR
+			diagnosis.MergeStrategy = strategy
+			switch strategy {
+			case stackedpr.MergeStrategySquash:
+				diagnosis.Reason = stackedpr.BrokenReasonBaseSquashMerged
+			case stackedpr.MergeStrategyRebaseMerge:
+				diagnosis.Reason = stackedpr.BrokenReasonBaseRebaseMerged
+			default:
+				diagnosis.Reason = stackedpr.BrokenReasonBaseMerged
+			}
+			return diagnosis, mergedBasePR.BaseRefName, upstream, nil
 		}
 	}
 
 	// --- Check 2: Has the PR diverged from its base? ---
 	// This can happen if the base branch itself was updated (e.g., parent PR rebased).
 	isDiverged := false
-	if _, ok := prMap[pr.BaseRefName]; ok { // Only check divergence for stacked PRs
-		baseShaOnOrigin, err := git.RevParse(ctx, "origin/"+pr.BaseRefName)
+	if _, ok := c.prMap[pr.BaseRefName]; ok { // Only check divergence for stacked PRs
+		baseShaOnOrigin, err := git.RevParse(ctx, git.RemoteRef(pr.BaseRefName))
 		if err != nil {
-			return false, "", "", fmt.Errorf("could not get SHA for base %s: %v", pr.BaseRefName, err)
+			return stackedpr.Diagnosis{}, "", "", fmt.Errorf("could not get SHA for base %s: %v", pr.BaseRefName, err)
 		}
-		headSha := prHeadShas[pr.HeadRefName]
-		mergeBase, err := git.GetMergeBase(ctx, "origin/"+pr.BaseRefName, headSha)
+		headSha := c.prHeadShas[pr.HeadRefName]
+		mergeBase, err := git.GetMergeBase(ctx, git.RemoteRef(pr.BaseRefName), headSha)
 		if err != nil {
-			return false, "", "", fmt.Errorf("could not get merge base for %s and %s: %v", pr.BaseRefName, pr.HeadRefName, err)
+			return stackedpr.Diagnosis{}, "", "", fmt.Errorf("could not get merge base for %s and %s: %v", pr.BaseRefName, pr.HeadRefName, err)
 		}
+		diagnosis.MergeBase = mergeBase
+		diagnosis.ExpectedSha = baseShaOnOrigin
 		if mergeBase != baseShaOnOrigin {
 			isDiverged = true
 		}
 	}
 
-	// --- Check 3: In a dry run, was the parent PR "rebased" earlier in this run? ---
-	isParentRebasedInDryRun := false
-	if cfg.DryRun {
-		if parentPR, ok := prMap[pr.BaseRefName]; ok {
-			baseShaInMap := prHeadShas[parentPR.HeadRefName]
-			if baseShaInMap == "dummy-sha-after-rebase" {
-				isParentRebasedInDryRun = true
-			}
-		}
+	// --- Check 3: Was the parent PR rebased earlier in this run without being pushed? ---
+	// In a dry run the parent is only planned to be rebased, and in atomic mode it is
+	// pushed at the end, so the remote-tracking branch doesn't reflect the rebase yet.
+	isParentRebasedInRun := false
+	if parentPR, ok := c.prMap[pr.BaseRefName]; ok {
+		isParentRebasedInRun = c.planned[parentPR.HeadRefName] || c.rebasedLocally[parentPR.HeadRefName]
 	}
 
-	if isDiverged || isParentRebasedInDryRun {
-		return true, "", "", nil // 'newBase' will be set to pr.BaseRefName by the caller
+	if isParentRebasedInRun {
+		diagnosis.Reason = stackedpr.BrokenReasonBaseRebased
+		return diagnosis, "", "", nil // 'newBase' will be set to pr.BaseRefName by the caller
+	}
+	if isDiverged {
+		diagnosis.Reason = stackedpr.BrokenReasonBaseDiverged
+		return diagnosis, "", "", nil
 	}
 
 	// --- Check 4: Does this root PR contain commits from another merged PR? ---
-	// This handles cases where a PR was based on another branch that got merged while this PR was open.
-	defaultBranch, err := git.GetDefaultBranch(ctx)
+	// This handles cases where a PR was based on another branch that got merged into its trunk while this PR was open.
+	isTrunk, err := c.cfg.TrunkBranches.Contains(ctx, pr.BaseRefName)
 	if err != nil {
-		return false, "", "", fmt.Errorf("could not get default branch: %v", err)
+		return stackedpr.Diagnosis{}, "", "", fmt.Errorf("could not get default branch: %v", err)
 	}
-	if pr.BaseRefName == defaultBranch {
-		headSha := prHeadShas[pr.HeadRefName]
-		mergeBase, err := git.GetMergeBase(ctx, "origin/"+defaultBranch, headSha)
+	if isTrunk {
+		headSha := c.prHeadShas[pr.HeadRefName]
+		mergeBase, err := git.GetMergeBase(ctx, git.RemoteRef(pr.BaseRefName), headSha)
 		if err != nil {
-			return false, "", "", fmt.Errorf("could not get merge base for %s: %v", pr.HeadRefName, err)
+			return stackedpr.Diagnosis{}, "", "", fmt.Errorf("could not get merge base for %s: %v", pr.HeadRefName, err)
 		}
+		diagnosis.MergeBase = mergeBase
 
-		// Find the PR that introduced the merge base commit.
-		for _, mergedPR := range mergedPRs {
+		// Find the PR merged into the trunk that introduced the merge base commit.
+		for _, mergedPR := range c.mergedPRs {
+			if mergedPR.BaseRefName != pr.BaseRefName {
+				continue
+			}
 			for _, commit := range mergedPR.Commits {
 				if mergeBase == commit.Oid {
-					return true, mergedPR.BaseRefName, "", nil
+					diagnosis.Reason = stackedpr.BrokenReasonContainsMergedCommits
+					diagnosis.MergedPR = &mergedPR
+					return diagnosis, mergedPR.BaseRefName, "", nil
 				}
 			}
 		}
 	}
 
-	return false, "", "", nil
+	return diagnosis, "", "", nil
 }
 
 // handleBrokenPR performs the necessary actions on a broken PR, such as rebasing,
 // pushing, and updating the base branch on the remote. It handles both dry-run and real modes.
-func handleBrokenPR(
-	ctx context.Context,
-	brokenPR stackedpr.RebaseInfo,
-	cfg Config,
-	prHeadShas map[string]string,
-) error {
-	if cfg.DryRun {
+// In atomic mode the push and the base branch update are deferred to pushPending.
+func (c *cascade) handleBrokenPR(ctx context.Context, brokenPR stackedpr.RebaseInfo) error {
+	if c.cfg.IsDryRun() {
 		updateBaseBranchString := ""
 		if brokenPR.PR.BaseRefName != brokenPR.NewBase {
 			updateBaseBranchString = fmt.Sprintf(" (update base branch to %s)", color.Cyan(brokenPR.NewBase))
 		}
-		write("  %s%s\n", brokenPR.PR.String(), updateBaseBranchString)
-		prHeadShas[brokenPR.PR.HeadRefName] = "dummy-sha-after-rebase"
+		predictionString := ""
+		if c.cfg.IsPredictConflicts() {
+			prediction, err := c.predictRebase(ctx, brokenPR)
+			if err != nil {
+				return err
+			}
+			brokenPR.Prediction = &prediction
+			predictionString = fmt.Sprintf(" [%s]", prediction.String())
+		}
+		c.printf("  %s%s%s\n", brokenPR.PR.String(), updateBaseBranchString, predictionString)
+		if c.cfg.IsVerbose() {
+			c.printDiagnosis(brokenPR, "    ")
+		}
+		c.planned[brokenPR.PR.HeadRefName] = true
 		return nil
 	}
 
 	// --- Rebase ---
-	if !cfg.Auto {
-		write("PR %s needs to be rebased onto %s\n", brokenPR.PR.String(), color.Cyan(brokenPR.NewBase))
-		var cmd string
-		if brokenPR.Upstream != "" {
-			cmd = fmt.Sprintf("git rebase --onto %s %s %s", "origin/"+brokenPR.NewBase, brokenPR.Upstream, brokenPR.PR.HeadRefName)
-		} else {
-			cmd = fmt.Sprintf("git rebase %s %s", "origin/"+brokenPR.NewBase, brokenPR.PR.HeadRefName)
+	if !c.cfg.IsAuto() || c.cfg.IsVerbose() {
+		c.printf("PR %s needs to be rebased onto %s\n", brokenPR.PR.String(), color.Cyan(brokenPR.NewBase))
+		if c.cfg.IsVerbose() {
+			c.printDiagnosis(brokenPR, "  ")
 		}
-		write("  Suggested command: %s\n", color.Yellow(cmd))
+	}
+	if !c.cfg.IsAuto() {
+		cmd := rebaseCommand(brokenPR.Onto(), brokenPR.Upstream, brokenPR.PR.HeadRefName)
+		c.printf("  Suggested command: %s\n", color.Yellow(cmd))
 		response, err := util.AskForConfirmation("Run this command?")
 		if err != nil {
 			return fmt.Errorf("error reading input: %s", err)
 		} else if !response {
-			write("Skipping.\n")
+			c.printf("Skipping.\n")
 			return nil // Not an error, just skipping this PR.
 		}
 	}
 
-	msg := fmt.Sprintf("Rebasing %s onto %s...", brokenPR.PR.String(), color.Cyan(brokenPR.NewBase))
-	if err := spinner.New(msg, cfg.Writer).Run(func() error {
-		return git.Rebase(ctx, fmt.Sprintf("origin/%s", brokenPR.NewBase), brokenPR.Upstream, brokenPR.PR.HeadRefName)
-	}); err != nil {
+	oldSha := c.prHeadShas[brokenPR.PR.HeadRefName]
+	if err := c.runPRHook(ctx, hookPreRebase, c.cfg.Hooks.PreRebase, brokenPR, oldSha, ""); err != nil {
+		return err
+	}
+
+	c.progress.Report(progress.RebaseStarted{PR: brokenPR.PR, NewBase: brokenPR.NewBase})
+	if err := git.Rebase(ctx, brokenPR.Onto(), brokenPR.Upstream, brokenPR.PR.HeadRefName); err != nil {
+		c.progress.Report(progress.RebaseFailed{PR: brokenPR.PR, NewBase: brokenPR.NewBase, Err: err})
 		if errors.Is(err, git.ErrRebaseConflict) {
 			// Attempt to abort the rebase if there was a conflict.
 			if err := git.AbortRebase(ctx); err != nil {
@@ -331,57 +610,286 @@ func handleBrokenPR(
 		}
 		return fmt.Errorf("failed to rebase %s onto %s: %v", brokenPR.PR.HeadRefName, brokenPR.NewBase, err)
 	}
-	success(msg)
+	c.progress.Report(progress.PRRebased{PR: brokenPR.PR, NewBase: brokenPR.NewBase})
+
+	// --- Close if empty ---
+	commits, err := git.GetBranchCommits(ctx, brokenPR.Onto(), brokenPR.PR.HeadRefName)
+	if err != nil {
+		return fmt.Errorf("could not get commits of %s: %v", brokenPR.PR.HeadRefName, err)
+	}
+	if len(commits) == 0 {
+		closed, err := c.closeEmptyPR(ctx, brokenPR)
+		if err != nil {
+			return err
+		}
+		if closed {
+			return nil
+		}
+	}
+
+	if err := c.runPostRebaseHook(ctx, brokenPR); err != nil {
+		return err
+	}
+	if err := c.verify(ctx, brokenPR, commits); err != nil {
+		return err
+	}
+
+	if c.cfg.IsAtomic() {
+		c.rebasedLocally[brokenPR.PR.HeadRefName] = true
+		c.pendingPushes.Enqueue(brokenPR)
+		return nil
+	}
 
 	// --- Push ---
-	if !cfg.Auto {
-		write("Rebase completed successfully.\n")
+	if !c.cfg.IsAuto() {
+		c.printf("Rebase completed successfully.\n")
 		response, err := util.AskForConfirmation("Continue to push the rebased branch and update the PR?")
 		if err != nil {
 			return fmt.Errorf("error reading input: %s", err)
 		} else if !response {
-			write("Skipping push and PR update.\n")
+			c.printf("Skipping push and PR update.\n")
 			return nil
 		}
 	}
 
-	msg = fmt.Sprintf("Pushing %s...", brokenPR.PR.PRNumberString())
-	if err := spinner.New(msg, cfg.Writer).Run(func() error {
-		return git.Push(ctx, brokenPR.PR.HeadRefName)
-	}); err != nil {
+	pushed := []gitobj.PullRequest{brokenPR.PR}
+	c.progress.Report(progress.PushStarted{PRs: pushed})
+	if err := git.Push(ctx, git.ForceWithLease{
+		Branch:      brokenPR.PR.HeadRefName,
+		ExpectedSha: c.prHeadShas[brokenPR.PR.HeadRefName],
+	}, c.cfg.PushOptions); err != nil {
+		c.progress.Report(progress.PushFailed{PRs: pushed, Err: err})
+		var errStaleLease *git.StaleLeaseError
+		if errors.As(err, &errStaleLease) {
+			return err
+		}
 		return fmt.Errorf("failed to push branch %s: %v", brokenPR.PR.HeadRefName, err)
 	}
-	success(msg)
+	c.progress.Report(progress.PRPushed{PRs: pushed})
 
-	newSha, err := git.RevParse(ctx, "origin/"+brokenPR.PR.HeadRefName)
+	newSha, err := git.RevParse(ctx, git.RemoteRef(brokenPR.PR.HeadRefName))
 	if err != nil {
 		return fmt.Errorf("could not get new SHA for %s: %v", brokenPR.PR.HeadRefName, err)
 	}
-	prHeadShas[brokenPR.PR.HeadRefName] = newSha
+	c.prHeadShas[brokenPR.PR.HeadRefName] = newSha
+	c.pushedPRs[brokenPR.PR.Number] = true
 
-	// --- Update Base Branch ---
-	if brokenPR.PR.BaseRefName != brokenPR.NewBase {
-		if !cfg.Auto {
-			write("Branch %s needs to be updated to base branch %s\n", color.Cyan(brokenPR.PR.HeadRefName), color.Cyan(brokenPR.NewBase))
-			cmd := fmt.Sprintf("gh pr edit %s --base %s", brokenPR.PR.PRNumberString(), brokenPR.NewBase)
-			write("  Suggested command: %s\n", color.Yellow(cmd))
-			response, err := util.AskForConfirmation("Run this command?")
-			if err != nil {
-				return fmt.Errorf("error reading input: %s", err)
-			} else if !response {
-				write("Skipping base branch update.\n")
-				return nil // Not an error, just skipping this PR.
-			}
+	if err := c.runPRHook(ctx, hookPostPush, c.cfg.Hooks.PostPush, brokenPR, oldSha, newSha); err != nil {
+		return err
+	}
+
+	return c.updateBaseBranch(ctx, brokenPR)
+}
+
+// closeEmptyPR closes a PR that has no commits left after the rebase, because all of them
+// were already merged into its new base by another route. The PRs stacked on top of it are
+// then rebased onto its new base, and retargeted to it.
+func (c *cascade) closeEmptyPR(ctx context.Context, brokenPR stackedpr.RebaseInfo) (bool, error) {
+	if !c.cfg.IsAuto() {
+		c.printf("PR %s has no changes left after the rebase onto %s\n", brokenPR.PR.String(), color.Cyan(brokenPR.NewBase))
+		response, err := util.AskForConfirmation("Close it and retarget the PRs based on it?")
+		if err != nil {
+			return false, fmt.Errorf("error reading input: %s", err)
+		} else if !response {
+			c.printf("Skipping.\n")
+			return false, nil
+		}
+	}
+
+	comment := fmt.Sprintf("All the commits of this pull request are already in `%s`, so there is nothing left to merge. "+
+		"The pull requests based on it are retargeted to `%s`.", brokenPR.NewBase, brokenPR.NewBase)
+
+	c.progress.Report(progress.CloseStarted{PR: brokenPR.PR})
+	if err := git.ClosePullRequest(ctx, brokenPR.PR.Number, comment); err != nil {
+		c.progress.Report(progress.CloseFailed{PR: brokenPR.PR, Err: err})
+		return false, fmt.Errorf("failed to close PR %s: %v", brokenPR.PR.PRNumberString(), err)
+	}
+	c.progress.Report(progress.PRClosed{PR: brokenPR.PR})
+
+	c.closedPRs[brokenPR.PR.HeadRefName] = brokenPR
+	return true, nil
+}
+
+// deleteClosedBranches deletes the branches of the PRs closed in this run, if told to.
+// It is done once the PRs based on them were retargeted, as GitHub closes the PRs whose base
+// branch is deleted, so a branch on which a PR is still based is kept.
+func (c *cascade) deleteClosedBranches(ctx context.Context) {
+	if !c.cfg.IsDeleteClosedBranches() {
+		return
+	}
+
+	branches := slices.Sorted(maps.Keys(c.closedPRs))
+	for _, branch := range branches {
+		hasOpenPRs, err := git.HasOpenPullRequests(ctx, branch)
+		if err != nil {
+			c.failure(fmt.Sprintf("Could not list the PRs based on %s, so it is kept: %v", branch, err))
+			continue
+		}
+		if hasOpenPRs {
+			c.printf("Keeping branch %s, as PRs are still based on it.\n", color.Blue(branch))
+			continue
+		}
+
+		c.progress.Report(progress.DeleteBranchStarted{Branch: branch})
+		if err := git.DeleteRemoteBranch(ctx, branch); err != nil {
+			c.progress.Report(progress.DeleteBranchFailed{Branch: branch, Err: err})
+			c.failure(fmt.Sprintf("Failed to delete branch %s: %v", branch, err))
+			continue
+		}
+		c.progress.Report(progress.BranchDeleted{Branch: branch})
+	}
+}
+
+// predictRebase simulates the rebase of the broken PR without touching any ref.
+// A PR whose parent is rebased in this run is simulated onto the simulated result of its parent.
+func (c *cascade) predictRebase(ctx context.Context, brokenPR stackedpr.RebaseInfo) (stackedpr.RebasePrediction, error) {
+	onto := brokenPR.Onto()
+	if parent, ok := c.predictions[brokenPR.NewBase]; ok {
+		if parent.Head == "" {
+			// The parent can't be rebased cleanly, so there is nothing to simulate onto.
+			prediction := stackedpr.RebasePrediction{Outcome: stackedpr.RebaseOutcomeUnknown}
+			c.predictions[brokenPR.PR.HeadRefName] = prediction
+			return prediction, nil
+		}
+		onto = parent.Head
+	}
+
+	prediction, err := stackedpr.PredictRebase(ctx, brokenPR, onto, c.prHeadShas[brokenPR.PR.HeadRefName])
+	if err != nil {
+		return stackedpr.RebasePrediction{}, err
+	}
+	c.predictions[brokenPR.PR.HeadRefName] = prediction
+	return prediction, nil
+}
+
+// pushPending pushes all the branches rebased in atomic mode with a single
+// `git push --atomic`, then updates the base branches of their PRs.
+// Each lease expects the SHA the branch had on the remote before the rebase.
+func (c *cascade) pushPending(ctx context.Context) error {
+	if c.pendingPushes.IsEmpty() {
+		return nil
+	}
+
+	var rebased []stackedpr.RebaseInfo
+	for !c.pendingPushes.IsEmpty() {
+		rebased = append(rebased, c.pendingPushes.MustDequeue())
+	}
+
+	leases := make([]git.ForceWithLease, 0, len(rebased))
+	pushed := make([]gitobj.PullRequest, 0, len(rebased))
+	for _, brokenPR := range rebased {
+		leases = append(leases, git.ForceWithLease{
+			Branch:      brokenPR.PR.HeadRefName,
+			ExpectedSha: c.prHeadShas[brokenPR.PR.HeadRefName],
+		})
+		pushed = append(pushed, brokenPR.PR)
+	}
+
+	if !c.cfg.IsAuto() {
+		c.printf("All PRs were rebased successfully.\n")
+		response, err := util.AskForConfirmation("Continue to push all the rebased branches atomically and update the PRs?")
+		if err != nil {
+			return fmt.Errorf("error reading input: %s", err)
+		} else if !response {
+			c.printf("Skipping push and PR update.\n")
+			return nil
 		}
+	}
 
-		msg = fmt.Sprintf("Updating base branch of %s to %s...", brokenPR.PR.PRNumberString(), color.Cyan(brokenPR.NewBase))
+	c.progress.Report(progress.PushStarted{PRs: pushed})
+	if err := git.PushAtomic(ctx, leases, c.cfg.PushOptions); err != nil {
+		c.progress.Report(progress.PushFailed{PRs: pushed, Err: err})
+		var errStaleLease *git.StaleLeaseError
+		if errors.As(err, &errStaleLease) {
+			return err
+		}
+		return fmt.Errorf("failed to push branches atomically: %v", err)
+	}
+	c.progress.Report(progress.PRPushed{PRs: pushed})
+
+	for _, brokenPR := range rebased {
+		newSha, err := git.RevParse(ctx, git.RemoteRef(brokenPR.PR.HeadRefName))
+		if err != nil {
+			return fmt.Errorf("could not get new SHA for %s: %v", brokenPR.PR.HeadRefName, err)
+		}
+		oldSha := c.prHeadShas[brokenPR.PR.HeadRefName]
+		c.prHeadShas[brokenPR.PR.HeadRefName] = newSha
+		c.pushedPRs[brokenPR.PR.Number] = true
+		delete(c.rebasedLocally, brokenPR.PR.HeadRefName)
+
+		if err := c.runPRHook(ctx, hookPostPush, c.cfg.Hooks.PostPush, brokenPR, oldSha, newSha); err != nil {
+			return err
+		}
 
-		if err = spinner.New(msg, cfg.Writer).Run(func() error {
-			return git.UpdateBaseBranch(ctx, brokenPR.PR.Number, brokenPR.NewBase)
-		}); err != nil {
-			return fmt.Errorf("failed to update base branch for PR %s: %v", brokenPR.PR.PRNumberString(), err)
+		if err := c.updateBaseBranch(ctx, brokenPR); err != nil {
+			return err
 		}
-		success(msg)
 	}
 	return nil
 }
+
+// updateBaseBranch changes the base branch of the PR on the remote if it has
+// been rebased onto another branch.
+func (c *cascade) updateBaseBranch(ctx context.Context, brokenPR stackedpr.RebaseInfo) error {
+	if brokenPR.PR.BaseRefName == brokenPR.NewBase {
+		return nil
+	}
+
+	if !c.cfg.IsAuto() {
+		c.printf("Branch %s needs to be updated to base branch %s\n", color.Cyan(brokenPR.PR.HeadRefName), color.Cyan(brokenPR.NewBase))
+		cmd := fmt.Sprintf("gh pr edit %s --base %s", brokenPR.PR.PRNumberString(), brokenPR.NewBase)
+		c.printf("  Suggested command: %s\n", color.Yellow(cmd))
+		response, err := util.AskForConfirmation("Run this command?")
+		if err != nil {
+			return fmt.Errorf("error reading input: %s", err)
+		} else if !response {
+			c.printf("Skipping base branch update.\n")
+			return nil // Not an error, just skipping this PR.
+		}
+	}
+
+	c.progress.Report(progress.BaseUpdateStarted{PR: brokenPR.PR, NewBase: brokenPR.NewBase})
+	if err := git.UpdateBaseBranch(ctx, brokenPR.PR.Number, brokenPR.NewBase); err != nil {
+		c.progress.Report(progress.BaseUpdateFailed{PR: brokenPR.PR, NewBase: brokenPR.NewBase, Err: err})
+		return fmt.Errorf("failed to update base branch for PR %s: %v", brokenPR.PR.PRNumberString(), err)
+	}
+	c.progress.Report(progress.BaseUpdated{PR: brokenPR.PR, NewBase: brokenPR.NewBase})
+
+	if c.cfg.IsUpdateDescriptions() {
+		return c.updateDescription(ctx, brokenPR)
+	}
+	return nil
+}
+
+const (
+	descriptionNoteStart = "<!-- gh-domino -->"
+	descriptionNoteEnd   = "<!-- /gh-domino -->"
+)
+
+// updateDescription notes in the description of the PR which base it was retargeted from.
+// The note is replaced on the next retarget instead of piling up.
+func (c *cascade) updateDescription(ctx context.Context, brokenPR stackedpr.RebaseInfo) error {
+	body, err := git.GetPullRequestBody(ctx, brokenPR.PR.Number)
+	if err != nil {
+		return fmt.Errorf("failed to get the description of PR %s: %v", brokenPR.PR.PRNumberString(), err)
+	}
+
+	note := fmt.Sprintf("Retargeted from `%s` to `%s` by gh-domino.", brokenPR.PR.BaseRefName, brokenPR.NewBase)
+	if err := git.EditPullRequestBody(ctx, brokenPR.PR.Number, withDescriptionNote(body, note)); err != nil {
+		return fmt.Errorf("failed to update the description of PR %s: %v", brokenPR.PR.PRNumberString(), err)
+	}
+	return nil
+}
+
+func withDescriptionNote(body, note string) string {
+	section := descriptionNoteStart + "\n" + note + "\n" + descriptionNoteEnd
+	start := strings.Index(body, descriptionNoteStart)
+	end := strings.Index(body, descriptionNoteEnd)
+	if start >= 0 && end > start {
+		return body[:start] + section + body[end+len(descriptionNoteEnd):]
+	}
+	if strings.TrimSpace(body) == "" {
+		return section
+	}
+	return strings.TrimRight(body, "\n") + "\n\n" + section
+}
@@ -2,7 +2,9 @@ package domino
 
 import (
 	"fmt"
+	"strings"
 
+	"github.com/134130/gh-domino/gitobj"
 	"github.com/134130/gh-domino/internal/color"
 	"github.com/134130/gh-domino/internal/stackedpr"
 )
@@ -14,15 +16,74 @@ type ErrRebaseConflict struct {
 var _ error = (*ErrRebaseConflict)(nil)
 
 func (e *ErrRebaseConflict) Error() string {
-	return fmt.Sprintf("failed to rebase %s onto origin/%s", e.BrokenPR.PR.HeadRefName, e.BrokenPR.NewBase)
+	return fmt.Sprintf("failed to rebase %s onto %s", e.BrokenPR.PR.HeadRefName, e.BrokenPR.Onto())
 }
 
 func (e *ErrRebaseConflict) Command() string {
-	newBase := color.Cyan(fmt.Sprintf("origin/%s", e.BrokenPR.NewBase))
-	branch := color.Blue(e.BrokenPR.PR.HeadRefName)
-	if e.BrokenPR.Upstream != "" {
-		upstream := color.Yellow(e.BrokenPR.Upstream)
+	upstream := e.BrokenPR.Upstream
+	if upstream != "" {
+		upstream = color.Yellow(upstream)
+	}
+	return rebaseCommand(color.Cyan(e.BrokenPR.Onto()), upstream, color.Blue(e.BrokenPR.PR.HeadRefName))
+}
+
+// PlainCommand returns the command to rebase manually like Command, without colors.
+func (e *ErrRebaseConflict) PlainCommand() string {
+	return rebaseCommand(e.BrokenPR.Onto(), e.BrokenPR.Upstream, e.BrokenPR.PR.HeadRefName)
+}
+
+func rebaseCommand(newBase, upstream, branch string) string {
+	if upstream != "" {
 		return fmt.Sprintf("git rebase --onto %s %s %s", newBase, upstream, branch)
 	}
 	return fmt.Sprintf("git rebase %s %s", newBase, branch)
 }
+
+// ErrHookFailed is returned when a hook command exits with an error, which stops the cascade.
+type ErrHookFailed struct {
+	Hook    string
+	Command string
+	Output  string
+	Err     error
+}
+
+var _ error = (*ErrHookFailed)(nil)
+
+func (e *ErrHookFailed) Error() string {
+	msg := fmt.Sprintf("%s hook `%s` failed: %v", e.Hook, e.Command, e.Err)
+	if output := strings.TrimSpace(e.Output); output != "" {
+		msg += "\n  " + strings.ReplaceAll(output, "\n", "\n  ")
+	}
+	return msg
+}
+
+func (e *ErrHookFailed) Unwrap() error {
+	return e.Err
+}
+
+// ErrExecFailed is returned when the verification command fails on a rebased PR, which is then not pushed.
+type ErrExecFailed struct {
+	PR      gitobj.PullRequest
+	Commit  string
+	Command string
+	Output  string
+	Err     error
+}
+
+var _ error = (*ErrExecFailed)(nil)
+
+func (e *ErrExecFailed) Error() string {
+	at := e.PR.PRNumberString()
+	if e.Commit != "" {
+		at = fmt.Sprintf("%s of %s", e.Commit, at)
+	}
+	msg := fmt.Sprintf("`%s` failed on %s: %v", e.Command, at, e.Err)
+	if output := strings.TrimSpace(e.Output); output != "" {
+		msg += "\n  " + strings.ReplaceAll(output, "\n", "\n  ")
+	}
+	return msg + fmt.Sprintf("\n  %s was not pushed. Its rebased branch is left as is in your local repository.", e.PR.PRNumberString())
+}
+
+func (e *ErrExecFailed) Unwrap() error {
+	return e.Err
+}
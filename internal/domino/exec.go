@@ -0,0 +1,52 @@
+package domino
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/134130/gh-domino/git"
+	"github.com/134130/gh-domino/internal/progress"
+	"github.com/134130/gh-domino/internal/stackedpr"
+)
+
+// verify runs the --exec command on the rebased PR before it is pushed, at its tip or on each of
+// its commits from the oldest one. The commits are the ones of the PR after the rebase, newest first.
+func (c *cascade) verify(ctx context.Context, brokenPR stackedpr.RebaseInfo, commits []string) error {
+	if c.cfg.Exec == "" || len(commits) == 0 {
+		return nil
+	}
+	env := prEnv(brokenPR, c.prHeadShas[brokenPR.PR.HeadRefName], commits[0])
+
+	if !c.cfg.IsExecEachCommit() {
+		return c.exec(ctx, brokenPR, "", env)
+	}
+
+	var execErr error
+	for i := len(commits) - 1; i >= 0 && execErr == nil; i-- {
+		if err := git.Checkout(ctx, commits[i], true); err != nil {
+			execErr = fmt.Errorf("could not check out %s: %v", commits[i], err)
+			break
+		}
+		execErr = c.exec(ctx, brokenPR, commits[i], append(env, "DOMINO_COMMIT="+commits[i]))
+	}
+	if err := git.Checkout(ctx, brokenPR.PR.HeadRefName, false); err != nil && execErr == nil {
+		return fmt.Errorf("could not check out %s back: %v", brokenPR.PR.HeadRefName, err)
+	}
+	return execErr
+}
+
+func (c *cascade) exec(ctx context.Context, brokenPR stackedpr.RebaseInfo, commit string, env []string) error {
+	c.progress.Report(progress.ExecStarted{PR: brokenPR.PR, Commit: commit})
+	if output, err := runShell(ctx, c.cfg.Exec, env); err != nil {
+		c.progress.Report(progress.ExecFailed{PR: brokenPR.PR, Commit: commit, Err: err})
+		return &ErrExecFailed{
+			PR:      brokenPR.PR,
+			Commit:  commit,
+			Command: c.cfg.Exec,
+			Output:  output,
+			Err:     err,
+		}
+	}
+	c.progress.Report(progress.ExecDone{PR: brokenPR.PR, Commit: commit})
+	return nil
+}
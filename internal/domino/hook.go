@@ -0,0 +1,106 @@
+package domino
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/134130/gh-domino/git"
+	"github.com/134130/gh-domino/gitobj"
+	"github.com/134130/gh-domino/internal/progress"
+	"github.com/134130/gh-domino/internal/stackedpr"
+)
+
+const (
+	hookPreRebase   = "pre-rebase"
+	hookPostRebase  = "post-rebase"
+	hookPostPush    = "post-push"
+	hookPostCascade = "post-cascade"
+)
+
+// runHook runs the command of the hook with sh, with the variables of env added to its environment.
+// The output of the command is only shown when it fails.
+func (c *cascade) runHook(ctx context.Context, hook, command string, pr *gitobj.PullRequest, env []string) error {
+	if command == "" {
+		return nil
+	}
+
+	c.progress.Report(progress.HookStarted{Hook: hook, PR: pr})
+	env = append([]string{"DOMINO_HOOK=" + hook}, env...)
+	if output, err := runShell(ctx, command, env); err != nil {
+		c.progress.Report(progress.HookFailed{Hook: hook, PR: pr, Err: err})
+		return &ErrHookFailed{
+			Hook:    hook,
+			Command: command,
+			Output:  output,
+			Err:     err,
+		}
+	}
+	c.progress.Report(progress.HookDone{Hook: hook, PR: pr})
+	return nil
+}
+
+// runShell runs the command with sh in the working tree, and returns its output.
+func runShell(ctx context.Context, command string, env []string) (string, error) {
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+	err := git.NewCommand("sh", "-c", command).Run(ctx, git.WithEnv(env...), git.WithStdout(stdout), git.WithStderr(stderr))
+	return stdout.String() + stderr.String(), err
+}
+
+// prEnv describes the PR to the commands run around its steps.
+func prEnv(brokenPR stackedpr.RebaseInfo, oldSha, newSha string) []string {
+	return []string{
+		fmt.Sprintf("DOMINO_PR_NUMBER=%d", brokenPR.PR.Number),
+		"DOMINO_PR_URL=" + brokenPR.PR.Url,
+		"DOMINO_HEAD_BRANCH=" + brokenPR.PR.HeadRefName,
+		"DOMINO_BASE_BRANCH=" + brokenPR.PR.BaseRefName,
+		"DOMINO_NEW_BASE=" + brokenPR.NewBase,
+		"DOMINO_OLD_SHA=" + oldSha,
+		"DOMINO_NEW_SHA=" + newSha,
+	}
+}
+
+// runPRHook runs a hook around the steps of the PR. The SHAs are the one the head branch pointed to
+// when it was fetched, and the one it points to after the step, if any.
+func (c *cascade) runPRHook(ctx context.Context, hook, command string, brokenPR stackedpr.RebaseInfo, oldSha, newSha string) error {
+	return c.runHook(ctx, hook, command, &brokenPR.PR, prEnv(brokenPR, oldSha, newSha))
+}
+
+// runPostRebaseHook runs the post-rebase hook in the working tree, where the rebased branch is checked out.
+// Its failure stops the cascade before the branch is pushed.
+func (c *cascade) runPostRebaseHook(ctx context.Context, brokenPR stackedpr.RebaseInfo) error {
+	if c.cfg.Hooks.PostRebase == "" {
+		return nil
+	}
+	newSha, err := git.RevParse(ctx, brokenPR.PR.HeadRefName)
+	if err != nil {
+		return fmt.Errorf("could not get SHA of the rebased %s: %v", brokenPR.PR.HeadRefName, err)
+	}
+	return c.runPRHook(ctx, hookPostRebase, c.cfg.Hooks.PostRebase, brokenPR, c.prHeadShas[brokenPR.PR.HeadRefName], newSha)
+}
+
+// runPostCascadeHook runs the post-cascade hook with the result of the run and the PRs that were pushed,
+// and returns the result of the run, which is a failure if the hook failed.
+func (c *cascade) runPostCascadeHook(ctx context.Context, result Result) Result {
+	prNumbers := make([]int, 0, len(c.pushedPRs))
+	for number := range c.pushedPRs {
+		prNumbers = append(prNumbers, number)
+	}
+	slices.Sort(prNumbers)
+	prs := make([]string, 0, len(prNumbers))
+	for _, number := range prNumbers {
+		prs = append(prs, fmt.Sprint(number))
+	}
+
+	env := []string{
+		"DOMINO_RESULT=" + result.String(),
+		"DOMINO_PUSHED_PRS=" + strings.Join(prs, " "),
+	}
+	if err := c.runHook(ctx, hookPostCascade, c.cfg.Hooks.PostCascade, nil, env); err != nil {
+		c.failure(err.Error())
+		return ResultFailed
+	}
+	return result
+}
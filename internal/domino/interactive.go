@@ -0,0 +1,165 @@
+package domino
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"slices"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/134130/gh-domino/git"
+	"github.com/134130/gh-domino/internal/progress"
+	"github.com/134130/gh-domino/internal/stackedpr"
+	"github.com/134130/gh-domino/internal/ui"
+)
+
+// plannedPR is a PR of the dependency tree, along with the rebase planned for it if it is broken,
+// or the error which prevented from telling whether it is.
+type plannedPR struct {
+	node      *stackedpr.Node
+	depth     int
+	rebase    *stackedpr.RebaseInfo
+	diagnosis stackedpr.Diagnosis
+	err       error
+}
+
+// plan walks the dependency trees like a dry run, without any output,
+// and returns each PR in the order they would be handled.
+func (c *cascade) plan(ctx context.Context, roots []*stackedpr.Node) []plannedPR {
+	var planned []plannedPR
+	visited := make(map[int]bool)
+
+	var walk func(node *stackedpr.Node, depth int)
+	walk = func(node *stackedpr.Node, depth int) {
+		if node == nil || visited[node.Value.Number] {
+			return
+		}
+		visited[node.Value.Number] = true
+
+		item := plannedPR{node: node, depth: depth}
+		diagnosis, newBase, upstream, err := c.determinePRState(ctx, node.Value)
+		item.diagnosis, item.err = diagnosis, err
+		if err == nil && diagnosis.IsBroken() && node.SkipReason == "" {
+			if newBase == "" {
+				newBase = node.Value.BaseRefName
+			}
+			item.rebase = &stackedpr.RebaseInfo{PR: node.Value, NewBase: newBase, Upstream: upstream, Diagnosis: diagnosis}
+			c.planned[node.Value.HeadRefName] = true
+		}
+		planned = append(planned, item)
+
+		for _, child := range node.Children {
+			walk(child, depth+1)
+		}
+	}
+	for _, root := range roots {
+		walk(root, 0)
+	}
+
+	// The plan is executed for real, so forget about what was only planned.
+	c.planned = make(map[string]bool)
+	return planned
+}
+
+// runInteractive shows the rebase plan in a TUI where the user can toggle the PRs to rebase
+// and change their new base, then executes it with live progress in the same view.
+func (c *cascade) runInteractive(ctx context.Context, cancel context.CancelFunc, roots []*stackedpr.Node) (Result, error) {
+	planned := c.plan(ctx, roots)
+
+	defaultBranch, err := git.GetDefaultBranch(ctx)
+	if err != nil {
+		return ResultFailed, fmt.Errorf("could not get default branch: %v", err)
+	}
+
+	items := make([]ui.PlanItem, 0, len(planned))
+	for _, p := range planned {
+		label := p.node.Value.String()
+		if p.node.SkipReason != "" {
+			label += fmt.Sprintf(" [skipped: %s]", p.node.SkipReason)
+		}
+		item := ui.PlanItem{
+			Label: label,
+			Depth: p.depth,
+		}
+		if p.rebase != nil {
+			item.Broken = true
+			for _, base := range []string{p.rebase.NewBase, p.node.Value.BaseRefName, defaultBranch} {
+				if !slices.Contains(item.Bases, base) {
+					item.Bases = append(item.Bases, base)
+				}
+			}
+		}
+		items = append(items, item)
+	}
+
+	// The selection in the view stands for the confirmations, and the view owns the terminal
+	// while executing, so nothing else must be written meanwhile.
+	execCfg := c.cfg
+	execCfg.Auto = ptr(true)
+	execCfg.Writer = io.Discard
+	quiet := *c
+	quiet.cfg = execCfg
+	quiet.progress = progress.Discard()
+
+	var failed error
+	m := ui.NewPlanModel(ctx, cancel, items, func(ctx context.Context, index int, newBase string) error {
+		brokenPR := quiet.replan(*planned[index].rebase, newBase)
+		if err := quiet.handleBrokenPR(ctx, brokenPR); err != nil {
+			failed = err
+			return err
+		}
+		return nil
+	})
+
+	opts := []tea.ProgramOption{tea.WithOutput(c.cfg.Writer), tea.WithContext(ctx)}
+	if c.cfg.Reader != nil {
+		opts = append(opts, tea.WithInput(c.cfg.Reader))
+	}
+	c.progress.Close()
+	_, err = tea.NewProgram(m, opts...).Run()
+	if err != nil {
+		return ResultFailed, fmt.Errorf("failed to run interactive view: %w", err)
+	}
+
+	c.printf("\n")
+	if m.Aborted() {
+		c.printf("Aborted. Nothing has been changed.\n")
+		return ResultNothingToDo, nil
+	}
+	if failed != nil {
+		return c.reportFailure(ctx, failed), nil
+	}
+
+	quiet.cfg = c.cfg
+	quiet.cfg.Auto = ptr(true)
+	quiet.progress = c.progress
+	if err := quiet.pushPending(ctx); err != nil {
+		c.failure(err.Error())
+		return ResultFailed, nil
+	}
+
+	if !slices.ContainsFunc(m.Items(), func(item ui.PlanItem) bool { return item.Status == ui.PlanItemDone }) {
+		c.success("No PRs were rebased.")
+		return ResultNothingToDo, nil
+	}
+	return ResultRebased, nil
+}
+
+// replan returns the planned rebase of the PR onto the chosen base, as things stand when it is executed.
+// The plan was made before executing anything, so if the new base was closed as empty meanwhile,
+// the PR takes its place on the base of the closed PR, like in Check 0 of determinePRState.
+func (c *cascade) replan(brokenPR stackedpr.RebaseInfo, newBase string) stackedpr.RebaseInfo {
+	brokenPR.NewBase = newBase
+	if closedPR, ok := c.closedPRs[newBase]; ok {
+		brokenPR.NewBase = closedPR.NewBase
+		brokenPR.Upstream = c.prHeadShas[closedPR.PR.HeadRefName]
+		brokenPR.Diagnosis = stackedpr.Diagnosis{Reason: stackedpr.BrokenReasonBaseClosed}
+	}
+	brokenPR.OntoLocal = c.rebasedLocally[brokenPR.NewBase]
+	return brokenPR
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
@@ -0,0 +1,129 @@
+package domino
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/134130/gh-domino/git"
+)
+
+// mirrorsDir returns where the mirrors of the repositories given with --repo are kept.
+func mirrorsDir() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "gh-domino", "repos"), nil
+}
+
+// repoSpec is a repository given as [HOST/]OWNER/NAME, like gh does.
+type repoSpec struct {
+	Host  string
+	Owner string
+	Name  string
+}
+
+func parseRepo(s string) (repoSpec, error) {
+	parts := strings.Split(s, "/")
+	var r repoSpec
+	switch len(parts) {
+	case 2:
+		r = repoSpec{Host: "github.com", Owner: parts[0], Name: parts[1]}
+	case 3:
+		r = repoSpec{Host: parts[0], Owner: parts[1], Name: parts[2]}
+	default:
+		return r, fmt.Errorf("invalid repository %q, expected [HOST/]OWNER/NAME", s)
+	}
+	for _, part := range []string{r.Host, r.Owner, r.Name} {
+		if part == "" || part == "." || part == ".." {
+			return r, fmt.Errorf("invalid repository %q, expected [HOST/]OWNER/NAME", s)
+		}
+	}
+	r.Name = strings.TrimSuffix(r.Name, ".git")
+	return r, nil
+}
+
+func (r repoSpec) String() string {
+	if r.Host == "github.com" {
+		return r.Owner + "/" + r.Name
+	}
+	return r.Host + "/" + r.Owner + "/" + r.Name
+}
+
+func (r repoSpec) URL() string {
+	return fmt.Sprintf("https://%s/%s/%s.git", r.Host, r.Owner, r.Name)
+}
+
+// useMirror makes the repository the one to operate on, from its mirror.
+func useMirror(ctx context.Context, repo string) error {
+	spec, err := parseRepo(repo)
+	if err != nil {
+		return err
+	}
+	root, err := mirrorsDir()
+	if err != nil {
+		return fmt.Errorf("could not find where to keep the mirror of %s: %w", spec, err)
+	}
+	worktree, err := prepareMirror(ctx, root, spec)
+	if err != nil {
+		return fmt.Errorf("failed to prepare the mirror of %s: %w", spec, err)
+	}
+	if err := os.Chdir(worktree); err != nil {
+		return err
+	}
+	// gh would otherwise tell the repository from the remotes, which may be ambiguous.
+	return os.Setenv("GH_REPO", spec.String())
+}
+
+// prepareMirror returns the worktree to operate on the repository from, in a bare mirror kept under root.
+// The mirror is cloned on first use, or fetched otherwise, and the worktree is left on a detached HEAD at
+// the default branch of the remote, where the settings of the repository are read from. With no branch
+// checked out, the next fetch can reset all the local branches to the remote ones, dropping whatever
+// a previous run left behind.
+func prepareMirror(ctx context.Context, root string, repo repoSpec) (string, error) {
+	mirror := filepath.Join(root, repo.Host, repo.Owner, repo.Name+".git")
+	worktree := filepath.Join(root, repo.Host, repo.Owner, repo.Name)
+
+	if _, err := os.Stat(worktree); err == nil {
+		// A previous run may have stopped in the middle of a rebase, or left the branch of a PR checked out,
+		// which the fetch could not update, and whose settings must not be trusted.
+		if err := git.DetachWorktree(ctx, worktree, "HEAD"); err != nil {
+			return "", err
+		}
+		if err := git.Fetch(ctx, git.Remote, git.WithDir(mirror)); err != nil {
+			return "", fmt.Errorf("failed to fetch %s: %w", repo, err)
+		}
+		if err := git.DetachWorktree(ctx, worktree, git.RemoteRef("HEAD")); err != nil {
+			return "", err
+		}
+		return worktree, nil
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return "", err
+	}
+
+	// Whatever is left of a clone which failed midway is started over.
+	if err := os.RemoveAll(mirror); err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(filepath.Dir(mirror), 0o755); err != nil {
+		return "", err
+	}
+	if err := git.InitMirror(ctx, mirror, repo.URL()); err != nil {
+		return "", fmt.Errorf("failed to create the mirror: %w", err)
+	}
+	if err := git.Fetch(ctx, git.Remote, git.WithDir(mirror)); err != nil {
+		return "", fmt.Errorf("failed to fetch %s: %w", repo, err)
+	}
+	if err := git.SetRemoteHead(ctx, mirror); err != nil {
+		return "", fmt.Errorf("failed to find the default branch of %s: %w", repo, err)
+	}
+	if err := git.AddWorktree(ctx, mirror, worktree, git.RemoteRef("HEAD")); err != nil {
+		return "", fmt.Errorf("failed to add a worktree: %w", err)
+	}
+	return worktree, nil
+}
@@ -0,0 +1,199 @@
+package domino
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/134130/gh-domino/git"
+)
+
+func TestParseRepo(t *testing.T) {
+	for s, want := range map[string]repoSpec{
+		"octo/hello":                    {Host: "github.com", Owner: "octo", Name: "hello"},
+		"octo/hello.git":                {Host: "github.com", Owner: "octo", Name: "hello"},
+		"github.example.com/octo/hello": {Host: "github.example.com", Owner: "octo", Name: "hello"},
+	} {
+		got, err := parseRepo(s)
+		assert.NoError(t, err, s)
+		assert.Equal(t, want, got, s)
+	}
+	for _, s := range []string{"hello", "octo/", "a/b/c/d", "octo/.."} {
+		_, err := parseRepo(s)
+		assert.Error(t, err, s)
+	}
+}
+
+func TestPrepareMirror(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+
+	// The mirror is cloned from a local repository standing for the one on GitHub.
+	upstream := t.TempDir()
+	gitConfig := filepath.Join(t.TempDir(), "gitconfig")
+	config := "[url \"" + upstream + "\"]\n\tinsteadOf = https://github.com/octo/hello.git\n" +
+		"[user]\n\tname = test\n\temail = test@example.com\n"
+	if err := os.WriteFile(gitConfig, []byte(config), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("GIT_CONFIG_GLOBAL", gitConfig)
+	t.Setenv("GIT_CONFIG_NOSYSTEM", "1")
+
+	run := func(dir string, args ...string) string {
+		t.Helper()
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("git %s: %v\n%s", strings.Join(args, " "), err, out)
+		}
+		return strings.TrimSpace(string(out))
+	}
+	run(upstream, "init", "--quiet", "--initial-branch=main")
+	run(upstream, "commit", "--quiet", "--allow-empty", "-m", "init")
+	run(upstream, "branch", "feature")
+
+	root := t.TempDir()
+	repo := repoSpec{Host: "github.com", Owner: "octo", Name: "hello"}
+	worktree, err := prepareMirror(t.Context(), root, repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, run(upstream, "rev-parse", "feature"), run(worktree, "rev-parse", "origin/feature"))
+	assert.Equal(t, "origin/main", run(worktree, "symbolic-ref", "--short", "refs/remotes/origin/HEAD"))
+
+	// A branch left checked out by a previous run, with changes of its own, is reset to the remote.
+	run(worktree, "checkout", "--quiet", "feature")
+	if err := os.WriteFile(filepath.Join(worktree, SettingsFileName), []byte("hooks:\n  post-push: evil\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	run(worktree, "add", SettingsFileName)
+	run(worktree, "commit", "--quiet", "-m", "local")
+	run(upstream, "commit", "--quiet", "--allow-empty", "-m", "second")
+
+	if again, err := prepareMirror(t.Context(), root, repo); err != nil || again != worktree {
+		t.Fatalf("prepareMirror() = %s, %v, want %s", again, err, worktree)
+	}
+	for _, ref := range []string{"feature", "origin/main", "main"} {
+		upstreamRef := strings.TrimPrefix(ref, "origin/")
+		assert.Equal(t, run(upstream, "rev-parse", upstreamRef), run(worktree, "rev-parse", ref), ref)
+	}
+	// The worktree is on the fetched default branch, so the settings of the branch are gone.
+	assert.Equal(t, run(upstream, "rev-parse", "main"), run(worktree, "rev-parse", "HEAD"))
+	assert.Equal(t, "HEAD", run(worktree, "rev-parse", "--abbrev-ref", "HEAD"))
+	assert.NoFileExists(t, filepath.Join(worktree, SettingsFileName))
+}
+
+func TestPrepareRunSettings(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+
+	// Each repository has settings of its own, and so does the one of the working directory.
+	run := func(dir string, args ...string) string {
+		t.Helper()
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("git %s: %v\n%s", strings.Join(args, " "), err, out)
+		}
+		return strings.TrimSpace(string(out))
+	}
+	newRepo := func(settings string) string {
+		t.Helper()
+		dir := t.TempDir()
+		run(dir, "init", "--quiet", "--initial-branch=main")
+		if err := os.WriteFile(filepath.Join(dir, SettingsFileName), []byte(settings), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		run(dir, "add", SettingsFileName)
+		run(dir, "commit", "--quiet", "-m", "init")
+		return dir
+	}
+
+	gitConfig := filepath.Join(t.TempDir(), "gitconfig")
+	if err := os.WriteFile(gitConfig, []byte("[user]\n\tname = test\n\temail = test@example.com\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("GIT_CONFIG_GLOBAL", gitConfig)
+	t.Setenv("GIT_CONFIG_NOSYSTEM", "1")
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	t.Setenv("GH_REPO", "")
+	t.Cleanup(func() {
+		git.UseDefaultBranch("")
+		git.MetadataCache = nil
+	})
+
+	hello := newRepo("skip-labels: [hello]\ndelete-branches: closed\n")
+	world := newRepo("skip-labels: [world]\nauthor: octocat\n")
+	cwd := newRepo("skip-labels: [cwd]\ndefault-branch: develop\n")
+	config := fmt.Sprintf("[url %q]\n\tinsteadOf = https://github.com/octo/hello.git\n", hello) +
+		fmt.Sprintf("[url %q]\n\tinsteadOf = https://github.com/octo/world.git\n", world)
+	f, err := os.OpenFile(gitConfig, os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.WriteString(config); err != nil {
+		t.Fatal(err)
+	}
+	_ = f.Close()
+	t.Chdir(cwd)
+
+	settings, err := LoadSettings(t.Context())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The flags win over the settings of whichever repository the run ends up on.
+	flags := Settings{Author: "hubot"}
+	cfg := Config{Repo: "octo/hello", Settings: settings.Merge(flags), Flags: flags}
+
+	got, err := prepareRun(t.Context(), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, Settings{SkipLabels: []string{"hello"}, DeleteBranches: DeleteBranchesClosed, Author: "hubot"}, got.Settings)
+
+	// The branch of a PR left checked out by a run, with settings of its own, is not where the next run
+	// reads the settings from.
+	worktree, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	run(hello, "checkout", "--quiet", "-b", "feature")
+	if err := os.WriteFile(filepath.Join(hello, SettingsFileName), []byte("hooks:\n  post-push: evil\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	run(hello, "commit", "--quiet", "--all", "-m", "change the settings")
+	run(hello, "checkout", "--quiet", "main")
+	run(worktree, "fetch", "--quiet", "origin")
+	run(worktree, "checkout", "--quiet", "feature")
+
+	got, err = prepareRun(t.Context(), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, Settings{SkipLabels: []string{"hello"}, DeleteBranches: DeleteBranchesClosed, Author: "hubot"}, got.Settings)
+	assert.Equal(t, run(hello, "rev-parse", "main"), run(worktree, "rev-parse", "HEAD"))
+
+	// The next run on another repository, such as a job of serve, starts from the mirror of the previous one.
+	cfg.Repo = "octo/world"
+	got, err = prepareRun(t.Context(), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, Settings{SkipLabels: []string{"world"}, Author: "hubot"}, got.Settings)
+
+	got, err = prepareRun(t.Context(), Config{Repo: "octo/world"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, Settings{SkipLabels: []string{"world"}, Author: "octocat"}, got.Settings)
+}
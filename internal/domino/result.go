@@ -0,0 +1,58 @@
+package domino
+
+// Result tells how a run ended, so that CI jobs and git hooks can act on it.
+type Result int
+
+const (
+	// ResultNothingToDo means that no PR was broken.
+	ResultNothingToDo Result = iota
+	// ResultRebased means that the broken PRs were rebased, or would be in a dry run.
+	ResultRebased
+	// ResultConflict means that a rebase stopped on conflicts, or would in a dry run.
+	ResultConflict
+	// ResultBroken means that the check found broken PRs.
+	ResultBroken
+	// ResultFailed means that the run stopped on an error which has already been reported.
+	ResultFailed
+)
+
+// ExitCode returns the exit code of the result in CI mode.
+func (r Result) ExitCode() int {
+	switch r {
+	case ResultRebased, ResultBroken:
+		return 3
+	case ResultConflict:
+		return 2
+	case ResultFailed:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// ActionExitCode returns the exit code of the result in a workflow, whose step only fails when the stacks
+// could not be cascaded.
+func (r Result) ActionExitCode() int {
+	switch r {
+	case ResultConflict, ResultFailed:
+		return r.ExitCode()
+	default:
+		return 0
+	}
+}
+
+// String returns the name of the result, as given to the post-cascade hook.
+func (r Result) String() string {
+	switch r {
+	case ResultRebased:
+		return "rebased"
+	case ResultConflict:
+		return "conflict"
+	case ResultBroken:
+		return "broken"
+	case ResultFailed:
+		return "failed"
+	default:
+		return "nothing-to-do"
+	}
+}
@@ -0,0 +1,294 @@
+package domino
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// WebhookSecretEnv is the environment variable holding the secret the webhooks are signed with.
+const WebhookSecretEnv = "GH_DOMINO_WEBHOOK_SECRET"
+
+// maxPayloadSize is the largest payload GitHub sends.
+const maxPayloadSize = 25 << 20
+
+// Job is the cascade to run for a PR merged into a repository.
+type Job struct {
+	// Repo is the repository as [HOST/]OWNER/NAME.
+	Repo string
+	// MergedPR is the number of the merged PR, whose children are cascaded.
+	MergedPR int
+	// Author is the author of the merged PR, whose PRs are cascaded.
+	Author string
+}
+
+// pullRequestEvent is the part of the pull_request webhook payload a job is made of.
+type pullRequestEvent struct {
+	Action      string `json:"action"`
+	PullRequest struct {
+		Number int  `json:"number"`
+		Merged bool `json:"merged"`
+		User   struct {
+			Login string `json:"login"`
+		} `json:"user"`
+	} `json:"pull_request"`
+	Repository struct {
+		FullName string `json:"full_name"`
+		HTMLURL  string `json:"html_url"`
+	} `json:"repository"`
+}
+
+// job returns the job to run for the event, if the event is a PR being merged.
+func (e pullRequestEvent) job() (Job, bool) {
+	if e.Action != "closed" || !e.PullRequest.Merged || e.Repository.FullName == "" {
+		return Job{}, false
+	}
+	repo := e.Repository.FullName
+	if u, err := url.Parse(e.Repository.HTMLURL); err == nil && u.Host != "" && u.Host != "github.com" {
+		repo = u.Host + "/" + repo
+	}
+	return Job{Repo: repo, MergedPR: e.PullRequest.Number, Author: e.PullRequest.User.Login}, true
+}
+
+// WebhookHandler accepts the pull_request webhooks of GitHub, and queues a job for each merged PR.
+type WebhookHandler struct {
+	secret []byte
+	jobs   *JobQueue
+}
+
+// NewWebhookHandler returns a handler verifying that the payloads are signed with the secret.
+func NewWebhookHandler(secret []byte, jobs *JobQueue) *WebhookHandler {
+	return &WebhookHandler{secret: secret, jobs: jobs}
+}
+
+func (h *WebhookHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	payload, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxPayloadSize))
+	if err != nil {
+		http.Error(w, "could not read the payload", http.StatusBadRequest)
+		return
+	}
+	if !h.verify(payload, r.Header.Get("X-Hub-Signature-256")) {
+		http.Error(w, "invalid signature", http.StatusUnauthorized)
+		return
+	}
+
+	switch r.Header.Get("X-GitHub-Event") {
+	case "ping":
+		w.WriteHeader(http.StatusNoContent)
+		return
+	case "pull_request":
+	default:
+		respond(w, http.StatusAccepted, "ignored event")
+		return
+	}
+
+	var event pullRequestEvent
+	if err := json.Unmarshal(payload, &event); err != nil {
+		http.Error(w, "invalid payload", http.StatusBadRequest)
+		return
+	}
+	job, ok := event.job()
+	if !ok {
+		respond(w, http.StatusAccepted, "ignored event")
+		return
+	}
+	if _, err := parseRepo(job.Repo); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	h.jobs.Add(job)
+	respond(w, http.StatusAccepted, "queued")
+}
+
+func respond(w http.ResponseWriter, status int, msg string) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(status)
+	_, _ = fmt.Fprintln(w, msg)
+}
+
+// verify tells whether the signature is the HMAC-SHA256 of the payload with the secret, as GitHub signs it.
+func (h *WebhookHandler) verify(payload []byte, signature string) bool {
+	hexDigest, ok := strings.CutPrefix(signature, "sha256=")
+	if !ok {
+		return false
+	}
+	digest, err := hex.DecodeString(hexDigest)
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(sha256.New, h.secret)
+	mac.Write(payload)
+	return hmac.Equal(digest, mac.Sum(nil))
+}
+
+// JobQueue runs the jobs one at a time. The jobs of a repository waiting to run are run at once,
+// and the repositories take turns in the order their first pending job came in.
+// The cascade works on the working directory and the state of the git package, which are shared
+// by the whole process, so the jobs of different repositories are not run concurrently either.
+type JobQueue struct {
+	run func(ctx context.Context, repo string, jobs []Job)
+
+	mu      sync.Mutex
+	pending map[string][]Job
+	order   []string
+	wake    chan struct{}
+}
+
+// NewJobQueue returns a queue running the pending jobs of a repository with run.
+func NewJobQueue(run func(ctx context.Context, repo string, jobs []Job)) *JobQueue {
+	return &JobQueue{
+		run:     run,
+		pending: make(map[string][]Job),
+		wake:    make(chan struct{}, 1),
+	}
+}
+
+// Add queues the job. A job for a merged PR already waiting to run is dropped.
+func (q *JobQueue) Add(job Job) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	jobs, ok := q.pending[job.Repo]
+	if !ok {
+		q.order = append(q.order, job.Repo)
+	}
+	if !slices.Contains(jobs, job) {
+		q.pending[job.Repo] = append(jobs, job)
+	}
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+// next takes the pending jobs of the next repository.
+func (q *JobQueue) next() (string, []Job, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if len(q.order) == 0 {
+		return "", nil, false
+	}
+	repo := q.order[0]
+	q.order = q.order[1:]
+	jobs := q.pending[repo]
+	delete(q.pending, repo)
+	return repo, jobs, true
+}
+
+// Run runs the jobs as they are queued, until the context is done.
+func (q *JobQueue) Run(ctx context.Context) {
+	for {
+		for {
+			repo, jobs, ok := q.next()
+			if !ok {
+				break
+			}
+			q.run(ctx, repo, jobs)
+			if ctx.Err() != nil {
+				return
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-q.wake:
+		}
+	}
+}
+
+// Serve listens for the webhooks of the merged PRs, and cascades their children in the mirror of their
+// repository, until the context is done.
+func Serve(ctx context.Context, cfg Config) error {
+	secret := os.Getenv(WebhookSecretEnv)
+	if secret == "" {
+		return fmt.Errorf("%s must be set to the secret of the webhook", WebhookSecretEnv)
+	}
+
+	jobs := NewJobQueue(func(ctx context.Context, repo string, jobs []Job) {
+		runJobs(ctx, cfg, repo, jobs)
+	})
+	server := &http.Server{
+		Addr:              cfg.Addr,
+		Handler:           NewWebhookHandler([]byte(secret), jobs),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		jobs.Run(ctx)
+	}()
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer shutdownCancel()
+		_ = server.Shutdown(shutdownCtx)
+	}()
+
+	_, _ = fmt.Fprintf(cfg.Writer, "Listening for webhooks on %s\n", cfg.Addr)
+	err := server.ListenAndServe()
+	cancel()
+	<-done
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
+
+// runJobs cascades the children of the PRs merged into the repository, in one run per author of the
+// merged PRs, whose PRs are handled unless the flags or the settings of the repository tell another author.
+func runJobs(ctx context.Context, cfg Config, repo string, jobs []Job) {
+	byAuthor := make(map[string][]int)
+	var authors []string
+	for _, job := range jobs {
+		author := job.Author
+		if _, ok := byAuthor[author]; !ok {
+			authors = append(authors, author)
+		}
+		byAuthor[author] = append(byAuthor[author], job.MergedPR)
+	}
+
+	for _, author := range authors {
+		jobCfg := cfg
+		jobCfg.Command = ""
+		jobCfg.CI = ptr(true)
+		jobCfg.Repo = repo
+		jobCfg.MergedPRs = byAuthor[author]
+		jobCfg.FallbackAuthor = author
+
+		_, _ = fmt.Fprintf(cfg.Writer, "Cascading the children of %s in %s\n", prNumbers(jobCfg.MergedPRs), repo)
+		result, err := RunWithResult(ctx, jobCfg)
+		if err != nil {
+			_, _ = fmt.Fprintf(cfg.Writer, "Failed to cascade %s: %v\n", repo, err)
+			continue
+		}
+		_, _ = fmt.Fprintf(cfg.Writer, "Cascaded the children of %s in %s: %s\n", prNumbers(jobCfg.MergedPRs), repo, result)
+	}
+}
+
+func prNumbers(numbers []int) string {
+	s := make([]string, 0, len(numbers))
+	for _, n := range numbers {
+		s = append(s, fmt.Sprintf("#%d", n))
+	}
+	return strings.Join(s, ", ")
+}
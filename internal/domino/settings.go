@@ -0,0 +1,192 @@
+package domino
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+
+	"github.com/goccy/go-yaml"
+
+	"github.com/134130/gh-domino/git"
+	"github.com/134130/gh-domino/internal/notify"
+	"github.com/134130/gh-domino/internal/stackedpr"
+)
+
+// SettingsFileName is the name of the settings file at the root of the repository.
+const SettingsFileName = ".gh-domino.yml"
+
+const (
+	// DeleteBranchesNever keeps the branches of the PRs closed by gh-domino.
+	DeleteBranchesNever = "never"
+	// DeleteBranchesClosed deletes the branches of the PRs closed because they were empty after the rebase.
+	DeleteBranchesClosed = "closed"
+)
+
+// Settings are the values read from the settings files, which the flags override.
+type Settings struct {
+	Remote             string           `yaml:"remote"`
+	DefaultBranch      string           `yaml:"default-branch"`
+	Author             string           `yaml:"author"`
+	PushOptions        []string         `yaml:"push-options"`
+	UpdateDescriptions *bool            `yaml:"update-descriptions"`
+	DeleteBranches     string           `yaml:"delete-branches"`
+	ProtectedBranches  []string         `yaml:"protected-branches"`
+	TrunkBranches      stackedpr.Trunks `yaml:"trunk-branches"`
+	SkipLabels         []string         `yaml:"skip-labels"`
+	SkipState          string           `yaml:"skip-state"`
+	Hooks              Hooks            `yaml:"hooks"`
+	Notify             Notifications    `yaml:"notify"`
+}
+
+// Hooks are the shell commands to run around the steps of the cascade.
+type Hooks struct {
+	PreRebase   string `yaml:"pre-rebase"`
+	PostRebase  string `yaml:"post-rebase"`
+	PostPush    string `yaml:"post-push"`
+	PostCascade string `yaml:"post-cascade"`
+}
+
+// Notifications are where to tell about the broken PRs whose rebase stopped on conflicts.
+type Notifications struct {
+	// Terminal is the escape sequence of the desktop notifications of the terminal: osc9 or osc777.
+	Terminal string `yaml:"terminal"`
+	// Webhook is the URL to post the notifications to as JSON.
+	Webhook string `yaml:"webhook"`
+	// Command is the shell command to run for each notification.
+	Command string `yaml:"command"`
+}
+
+// LoadSettings reads the user-level settings file, then the one of the repository on top of it.
+// A missing file is not an error.
+func LoadSettings(ctx context.Context) (Settings, error) {
+	var paths []string
+	if dir, err := os.UserConfigDir(); err == nil {
+		paths = append(paths, filepath.Join(dir, "gh-domino", "config.yml"))
+	}
+	if root, err := git.GetTopLevel(ctx); err == nil {
+		paths = append(paths, filepath.Join(root, SettingsFileName))
+	}
+
+	var s Settings
+	for _, path := range paths {
+		file, err := ReadSettingsFile(path)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		} else if err != nil {
+			return s, err
+		}
+		s = s.Merge(file)
+	}
+	return s, nil
+}
+
+// ReadSettingsFile reads and validates one settings file.
+func ReadSettingsFile(path string) (Settings, error) {
+	var s Settings
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return s, err
+	}
+	if err := yaml.UnmarshalWithOptions(data, &s, yaml.Strict()); err != nil {
+		return s, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	if err := s.validate(); err != nil {
+		return s, fmt.Errorf("invalid settings in %s: %w", path, err)
+	}
+	return s, nil
+}
+
+// Merge returns the settings with the values set in other taking precedence.
+func (s Settings) Merge(other Settings) Settings {
+	if other.Remote != "" {
+		s.Remote = other.Remote
+	}
+	if other.DefaultBranch != "" {
+		s.DefaultBranch = other.DefaultBranch
+	}
+	if other.Author != "" {
+		s.Author = other.Author
+	}
+	if other.PushOptions != nil {
+		s.PushOptions = other.PushOptions
+	}
+	if other.UpdateDescriptions != nil {
+		s.UpdateDescriptions = other.UpdateDescriptions
+	}
+	if other.DeleteBranches != "" {
+		s.DeleteBranches = other.DeleteBranches
+	}
+	if other.ProtectedBranches != nil {
+		s.ProtectedBranches = other.ProtectedBranches
+	}
+	if other.TrunkBranches != nil {
+		s.TrunkBranches = other.TrunkBranches
+	}
+	if other.SkipLabels != nil {
+		s.SkipLabels = other.SkipLabels
+	}
+	if other.SkipState != "" {
+		s.SkipState = other.SkipState
+	}
+	if other.Hooks.PreRebase != "" {
+		s.Hooks.PreRebase = other.Hooks.PreRebase
+	}
+	if other.Hooks.PostRebase != "" {
+		s.Hooks.PostRebase = other.Hooks.PostRebase
+	}
+	if other.Hooks.PostPush != "" {
+		s.Hooks.PostPush = other.Hooks.PostPush
+	}
+	if other.Hooks.PostCascade != "" {
+		s.Hooks.PostCascade = other.Hooks.PostCascade
+	}
+	if other.Notify.Terminal != "" {
+		s.Notify.Terminal = other.Notify.Terminal
+	}
+	if other.Notify.Webhook != "" {
+		s.Notify.Webhook = other.Notify.Webhook
+	}
+	if other.Notify.Command != "" {
+		s.Notify.Command = other.Notify.Command
+	}
+	return s
+}
+
+func (s Settings) validate() error {
+	switch s.DeleteBranches {
+	case "", DeleteBranchesNever, DeleteBranchesClosed:
+	default:
+		return fmt.Errorf("delete-branches must be %q or %q, got %q", DeleteBranchesNever, DeleteBranchesClosed, s.DeleteBranches)
+	}
+	switch s.SkipState {
+	case "", stackedpr.SkipStateDraft, stackedpr.SkipStateReady:
+	default:
+		return fmt.Errorf("skip-state must be %q or %q, got %q", stackedpr.SkipStateDraft, stackedpr.SkipStateReady, s.SkipState)
+	}
+	for _, pattern := range s.ProtectedBranches {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid protected branch pattern %q: %w", pattern, err)
+		}
+	}
+	for _, pattern := range s.TrunkBranches {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid trunk branch pattern %q: %w", pattern, err)
+		}
+	}
+	switch s.Notify.Terminal {
+	case "", notify.ProtocolOSC9, notify.ProtocolOSC777:
+	default:
+		return fmt.Errorf("notify.terminal must be %q or %q, got %q", notify.ProtocolOSC9, notify.ProtocolOSC777, s.Notify.Terminal)
+	}
+	if s.Notify.Webhook != "" {
+		if u, err := url.Parse(s.Notify.Webhook); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("notify.webhook must be an http or https URL, got %q", s.Notify.Webhook)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,140 @@
+package domino
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/134130/gh-domino/internal/stackedpr"
+)
+
+func writeSettingsFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), SettingsFileName)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestReadSettingsFile(t *testing.T) {
+	path := writeSettingsFile(t, `
+remote: upstream
+default-branch: develop
+author: octocat
+push-options:
+  - ci.skip
+update-descriptions: true
+delete-branches: closed
+protected-branches:
+  - release
+trunk-branches:
+  - release/*
+hooks:
+  post-rebase: go build ./...
+notify:
+  terminal: osc777
+  webhook: https://example.com/hooks/domino
+`)
+
+	s, err := ReadSettingsFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	updateDescriptions := true
+	want := Settings{
+		Remote:             "upstream",
+		DefaultBranch:      "develop",
+		Author:             "octocat",
+		PushOptions:        []string{"ci.skip"},
+		UpdateDescriptions: &updateDescriptions,
+		DeleteBranches:     DeleteBranchesClosed,
+		ProtectedBranches:  []string{"release"},
+		TrunkBranches:      stackedpr.Trunks{"release/*"},
+		Hooks:              Hooks{PostRebase: "go build ./..."},
+		Notify:             Notifications{Terminal: "osc777", Webhook: "https://example.com/hooks/domino"},
+	}
+	assert.Equal(t, want, s)
+}
+
+func TestReadSettingsFileInvalid(t *testing.T) {
+	for name, content := range map[string]string{
+		"unknown key":             "remotes: upstream\n",
+		"unknown deletion policy": "delete-branches: merged\n",
+		"unknown terminal":        "notify:\n  terminal: bell\n",
+		"invalid webhook":         "notify:\n  webhook: example.com/hooks\n",
+	} {
+		t.Run(name, func(tt *testing.T) {
+			_, err := ReadSettingsFile(writeSettingsFile(tt, content))
+			assert.Error(tt, err)
+		})
+	}
+}
+
+func TestSettingsMerge(t *testing.T) {
+	updateDescriptions := false
+	user := Settings{
+		Remote:      "upstream",
+		Author:      "octocat",
+		PushOptions: []string{"ci.skip"},
+		Hooks:       Hooks{PreRebase: "make generate", PostPush: "notify"},
+		Notify:      Notifications{Terminal: "osc9", Command: "notify-send domino"},
+	}
+	repo := Settings{
+		Remote:             "fork",
+		PushOptions:        []string{},
+		UpdateDescriptions: &updateDescriptions,
+		Hooks:              Hooks{PreRebase: "go generate ./..."},
+		Notify:             Notifications{Webhook: "https://example.com/hooks/domino"},
+	}
+
+	got := user.Merge(repo)
+	want := Settings{
+		Remote:             "fork",
+		Author:             "octocat",
+		PushOptions:        []string{},
+		UpdateDescriptions: &updateDescriptions,
+		Hooks:              Hooks{PreRebase: "go generate ./...", PostPush: "notify"},
+		Notify: Notifications{
+			Terminal: "osc9",
+			Webhook:  "https://example.com/hooks/domino",
+			Command:  "notify-send domino",
+		},
+	}
+	assert.Equal(t, want, got)
+}
+
+func TestWithDescriptionNote(t *testing.T) {
+	note := "Retargeted from `feature-1` to `main` by gh-domino."
+	section := descriptionNoteStart + "\n" + note + "\n" + descriptionNoteEnd
+
+	testcases := []struct {
+		name string
+		body string
+		want string
+	}{
+		{
+			name: "empty body",
+			body: "",
+			want: section,
+		},
+		{
+			name: "appended to the body",
+			body: "Adds the feature.\n",
+			want: "Adds the feature.\n\n" + section,
+		},
+		{
+			name: "replaces the previous note",
+			body: "Adds the feature.\n\n" + descriptionNoteStart + "\nRetargeted from `a` to `b` by gh-domino.\n" + descriptionNoteEnd + "\n\nMore.",
+			want: "Adds the feature.\n\n" + section + "\n\nMore.",
+		},
+	}
+	for _, tc := range testcases {
+		t.Run(tc.name, func(tt *testing.T) {
+			assert.Equal(tt, tc.want, withDescriptionNote(tc.body, note))
+		})
+	}
+}
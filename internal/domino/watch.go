@@ -0,0 +1,265 @@
+package domino
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/134130/gh-domino/internal/color"
+	"github.com/134130/gh-domino/internal/progress"
+	"github.com/134130/gh-domino/internal/stackedpr"
+	"github.com/134130/gh-domino/internal/ui"
+)
+
+// defaultWatchInterval is how often the PRs are polled by watch, unless told otherwise.
+const defaultWatchInterval = 2 * time.Minute
+
+// Watcher polls the PRs of the author, and cascades the children of the PRs merged since the previous poll,
+// or tells how to without auto mode.
+type Watcher struct {
+	cfg Config
+	// handled holds the merged PRs whose children were cascaded or notified about already.
+	handled map[int]bool
+	// synced holds when each stack, by the number of its root PR, was last cascaded, and how it ended.
+	synced map[int]watchSync
+	// send shows what the watcher does, on the dashboard or as plain lines.
+	send func(tea.Msg)
+	// events renders the steps of the cascades.
+	events progress.Reporter
+}
+
+type watchSync struct {
+	at     time.Time
+	result Result
+}
+
+// NewWatcher returns a watcher printing plain lines to the writer of the configuration.
+func NewWatcher(cfg Config) *Watcher {
+	w := &Watcher{
+		cfg:     cfg,
+		handled: make(map[int]bool),
+		synced:  make(map[int]watchSync),
+		events:  progress.NewPlain(cfg.Writer),
+	}
+	w.send = w.print
+	return w
+}
+
+// Watch polls the PRs until the context is done, showing the stacks on a live dashboard when the
+// writer is a terminal.
+func Watch(ctx context.Context, cfg Config) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	w := NewWatcher(cfg)
+	if cfg.IsCI() || !progress.IsTerminal(cfg.Writer) {
+		if cfg.IsCI() {
+			defer color.Disable()()
+		}
+		_, _ = fmt.Fprintf(cfg.Writer, "Watching the PRs of %s every %s\n", cfg.AuthorFilter(), cfg.WatchInterval())
+		w.loop(ctx)
+		return nil
+	}
+
+	m := ui.NewWatchModel(ctx, cancel, "", cfg.WatchInterval())
+	p := tea.NewProgram(m, tea.WithOutput(cfg.Writer), tea.WithContext(ctx))
+	w.send = func(msg tea.Msg) {
+		if line, ok := msg.(ui.LogMsg); ok {
+			msg = ui.LogMsg(fmt.Sprintf("%s %s", time.Now().Format(time.TimeOnly), line))
+		}
+		p.Send(msg)
+	}
+	w.events = dashboardReporter{send: w.send}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		w.loop(ctx)
+	}()
+	_, err := p.Run()
+	cancel()
+	<-done
+	if err != nil && !errors.Is(err, tea.ErrProgramKilled) {
+		return fmt.Errorf("failed to run the dashboard: %w", err)
+	}
+	return nil
+}
+
+// loop polls the PRs right away, then on each interval until the context is done.
+func (w *Watcher) loop(ctx context.Context) {
+	ticker := time.NewTicker(w.cfg.WatchInterval())
+	defer ticker.Stop()
+	for {
+		// A failed poll is shown, and the next one tries again.
+		_ = w.Poll(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Poll fetches the PRs, and cascades the children of the PRs merged since the previous poll in auto mode,
+// or tells how to otherwise. The stacks based on a merged PR are all handled by the first poll.
+func (w *Watcher) Poll(ctx context.Context) error {
+	cfg, s, err := w.fetch(ctx)
+	if err != nil {
+		w.send(ui.WatchPolledMsg{At: time.Now(), Err: err})
+		return err
+	}
+
+	if merged := w.newlyMerged(s.roots); len(merged) > 0 {
+		if w.cfg.IsAuto() {
+			w.cascade(ctx, cfg, s, merged)
+		} else {
+			w.notify(s.roots, merged)
+		}
+	}
+	w.send(ui.WatchPolledMsg{Stacks: w.stacks(s.roots), At: time.Now()})
+	return nil
+}
+
+// fetch fetches the stacks, along with the configuration they were fetched with, which has the settings
+// of the repository under --repo, so that they are cascaded with the same one.
+func (w *Watcher) fetch(ctx context.Context) (Config, *stacks, error) {
+	w.send(ui.WatchBusyMsg("Polling the PRs..."))
+	defer w.send(ui.WatchBusyMsg(""))
+
+	cfg, err := prepareRun(ctx, w.cfg)
+	if err != nil {
+		return cfg, nil, err
+	}
+	s, err := fetchStacks(ctx, cfg, progress.Discard())
+	return cfg, s, err
+}
+
+// newlyMerged returns the merged PRs the stacks were based on, which were not handled yet.
+func (w *Watcher) newlyMerged(roots []*stackedpr.Node) []int {
+	var merged []int
+	seen := make(map[int]bool)
+	for _, root := range roots {
+		if root.OriginalBase == nil {
+			continue
+		}
+		number := root.OriginalBase.Number
+		if !w.handled[number] && !seen[number] {
+			seen[number] = true
+			merged = append(merged, number)
+		}
+	}
+	return merged
+}
+
+// cascade rebases the stacks based on the merged PRs. They are tried again on the next poll if the
+// cascade could not run or failed, but not if it stopped on a conflict, which needs to be resolved by hand.
+func (w *Watcher) cascade(ctx context.Context, cfg Config, s *stacks, merged []int) {
+	w.send(ui.WatchBusyMsg(fmt.Sprintf("Cascading the children of %s...", prNumbers(merged))))
+	defer w.send(ui.WatchBusyMsg(""))
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	cfg.Command = ""
+	cfg.MergedPRs = merged
+	r := w.events
+	if cfg.Observer != nil {
+		r = progress.Tee(r, cfg.Observer)
+	}
+	result, err := runStacks(ctx, cancel, cfg, r, s)
+	r.Close()
+	if err != nil {
+		w.log(fmt.Sprintf("Failed to cascade the children of %s: %v", prNumbers(merged), err))
+		return
+	}
+
+	now := time.Now()
+	for _, root := range stackedpr.ChildrenOf(s.roots, merged) {
+		w.synced[root.Value.Number] = watchSync{at: now, result: result}
+	}
+	if result == ResultFailed {
+		w.log(fmt.Sprintf("Cascaded the children of %s: %s, trying again on the next poll", prNumbers(merged), result))
+		return
+	}
+	for _, number := range merged {
+		w.handled[number] = true
+	}
+	w.log(fmt.Sprintf("Cascaded the children of %s: %s", prNumbers(merged), result))
+}
+
+// notify tells how to cascade the stacks based on the merged PRs, once for each of them.
+func (w *Watcher) notify(roots []*stackedpr.Node, merged []int) {
+	for _, number := range merged {
+		var children []int
+		for _, root := range stackedpr.ChildrenOf(roots, []int{number}) {
+			children = append(children, root.Value.Number)
+		}
+		w.log(fmt.Sprintf("#%d was merged, run %s to rebase %s", number, w.cascadeCommand(), prNumbers(children)))
+		w.handled[number] = true
+	}
+}
+
+// stacks returns the stacks to show on the dashboard, with how they were last cascaded.
+func (w *Watcher) stacks(roots []*stackedpr.Node) []ui.WatchStack {
+	stacks := make([]ui.WatchStack, 0, len(roots))
+	for _, root := range roots {
+		stack := ui.WatchStack{Tree: stackedpr.RenderStack(root)}
+		if sync, ok := w.synced[root.Value.Number]; ok {
+			stack.SyncedAt = sync.at
+			stack.Status = sync.result.String()
+		} else if root.OriginalBase != nil {
+			stack.Status = fmt.Sprintf("needs to be rebased with %s", w.cascadeCommand())
+		}
+		stacks = append(stacks, stack)
+	}
+	return stacks
+}
+
+// cascadeCommand returns the command to cascade the stacks by hand, for the same repository and author.
+func (w *Watcher) cascadeCommand() string {
+	cmd := "gh domino"
+	if w.cfg.Repo != "" {
+		cmd += " --repo " + w.cfg.Repo
+	}
+	if w.cfg.Author != "" {
+		cmd += " --author " + w.cfg.Author
+	}
+	return cmd
+}
+
+func (w *Watcher) log(line string) {
+	w.send(ui.LogMsg(line))
+}
+
+// print shows what the watcher does as plain lines, when there is no dashboard.
+func (w *Watcher) print(msg tea.Msg) {
+	switch msg := msg.(type) {
+	case ui.LogMsg:
+		_, _ = fmt.Fprintln(w.cfg.Writer, string(msg))
+	case ui.WatchPolledMsg:
+		if msg.Err != nil {
+			_, _ = fmt.Fprintf(w.cfg.Writer, "%s Failed to poll the PRs: %v\n", color.Red("✘"), msg.Err)
+		}
+	}
+}
+
+// dashboardReporter shows the steps of the cascades in the log of the dashboard.
+type dashboardReporter struct {
+	send func(tea.Msg)
+}
+
+var _ progress.Reporter = dashboardReporter{}
+
+func (r dashboardReporter) Report(e progress.Event) {
+	switch e.Kind() {
+	case progress.KindSucceeded:
+		r.send(ui.LogMsg(fmt.Sprintf("%s %s", color.Green("✔"), e.Message())))
+	case progress.KindFailed:
+		r.send(ui.LogMsg(fmt.Sprintf("%s %s", color.Red("✘"), e.Message())))
+	}
+}
+
+func (r dashboardReporter) Close() {}
@@ -0,0 +1,163 @@
+// Package notify tells the user about the broken PRs gh-domino could not fix on its own,
+// for when nobody watches its output.
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/134130/gh-domino/git"
+)
+
+const (
+	// EventRebaseConflict is a broken PR whose rebase stopped on conflicts, which need to be resolved by hand.
+	EventRebaseConflict = "rebase-conflict"
+)
+
+const (
+	// ProtocolOSC9 is the notification escape sequence of iTerm2, also understood by Windows Terminal,
+	// kitty, WezTerm and ghostty.
+	ProtocolOSC9 = "osc9"
+	// ProtocolOSC777 is the notification escape sequence of rxvt-unicode, also understood by foot,
+	// Konsole and WezTerm.
+	ProtocolOSC777 = "osc777"
+)
+
+// Notification tells about a broken PR, and what to do about it.
+type Notification struct {
+	Event      string `json:"event"`
+	PR         int    `json:"pr"`
+	Title      string `json:"title"`
+	URL        string `json:"url"`
+	HeadBranch string `json:"headBranch"`
+	BaseBranch string `json:"baseBranch"`
+	NewBase    string `json:"newBase"`
+	// Command is the command to rebase the PR by hand.
+	Command string `json:"command"`
+}
+
+// Message describes the notification in one line.
+func (n Notification) Message() string {
+	return fmt.Sprintf("#%d %s could not be rebased onto %s because of conflicts, run: %s", n.PR, n.Title, n.NewBase, n.Command)
+}
+
+// Sink sends the notifications somewhere the user will see them.
+type Sink interface {
+	Notify(ctx context.Context, n Notification) error
+}
+
+// Terminal shows the notifications as desktop notifications through the terminal emulator,
+// with an escape sequence written to the terminal.
+type Terminal struct {
+	w        io.Writer
+	protocol string
+}
+
+var _ Sink = (*Terminal)(nil)
+
+// NewTerminal returns a sink writing the escape sequence of the protocol to w.
+func NewTerminal(w io.Writer, protocol string) *Terminal {
+	return &Terminal{w: w, protocol: protocol}
+}
+
+func (t *Terminal) Notify(_ context.Context, n Notification) error {
+	// A control character in the text would end the sequence early.
+	body := strings.Map(func(r rune) rune {
+		if r < ' ' || r == 0x7f {
+			return ' '
+		}
+		return r
+	}, n.Message())
+
+	var seq string
+	switch t.protocol {
+	case ProtocolOSC777:
+		seq = fmt.Sprintf("\x1b]777;notify;gh-domino;%s\x07", body)
+	default:
+		seq = fmt.Sprintf("\x1b]9;gh-domino: %s\x07", body)
+	}
+	_, err := io.WriteString(t.w, seq)
+	return err
+}
+
+// Webhook posts the notifications as JSON to a URL.
+type Webhook struct {
+	url    string
+	client *http.Client
+}
+
+var _ Sink = (*Webhook)(nil)
+
+func NewWebhook(url string) *Webhook {
+	return &Webhook{url: url, client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+func (w *Webhook) Notify(ctx context.Context, n Notification) error {
+	payload, err := json.Marshal(n)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.url, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "gh-domino")
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to post the notification to %s: %w", w.url, err)
+	}
+	defer resp.Body.Close() //nolint:errcheck
+	_, _ = io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("failed to post the notification to %s: %s", w.url, resp.Status)
+	}
+	return nil
+}
+
+// Command runs a shell command for each notification, which is given in its environment,
+// and as JSON on its standard input.
+type Command struct {
+	command string
+}
+
+var _ Sink = (*Command)(nil)
+
+func NewCommand(command string) *Command {
+	return &Command{command: command}
+}
+
+func (c *Command) Notify(ctx context.Context, n Notification) error {
+	payload, err := json.Marshal(n)
+	if err != nil {
+		return err
+	}
+	env := []string{
+		"DOMINO_EVENT=" + n.Event,
+		fmt.Sprintf("DOMINO_PR_NUMBER=%d", n.PR),
+		"DOMINO_PR_URL=" + n.URL,
+		"DOMINO_HEAD_BRANCH=" + n.HeadBranch,
+		"DOMINO_BASE_BRANCH=" + n.BaseBranch,
+		"DOMINO_NEW_BASE=" + n.NewBase,
+		"DOMINO_COMMAND=" + n.Command,
+		"DOMINO_MESSAGE=" + n.Message(),
+	}
+	output := &bytes.Buffer{}
+	err = git.NewCommand("sh", "-c", c.command).Run(ctx,
+		git.WithEnv(env...), git.WithStdin(bytes.NewReader(payload)), git.WithCombinedOutput(output))
+	if err != nil {
+		var details string
+		if out := strings.TrimSpace(output.String()); out != "" {
+			details = "\n  " + strings.ReplaceAll(out, "\n", "\n  ")
+		}
+		return fmt.Errorf("notification command `%s` failed: %w%s", c.command, err, details)
+	}
+	return nil
+}
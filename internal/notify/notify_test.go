@@ -0,0 +1,95 @@
+package notify
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var conflict = Notification{
+	Event:      EventRebaseConflict,
+	PR:         115,
+	Title:      "bbb",
+	URL:        "https://github.com/134130/test-domino/pull/115",
+	HeadBranch: "feature-b",
+	BaseBranch: "feature-a",
+	NewBase:    "main",
+	Command:    "git rebase --onto origin/main 2e6584b feature-b",
+}
+
+func TestTerminal(t *testing.T) {
+	for protocol, want := range map[string]string{
+		ProtocolOSC9:   "\x1b]9;gh-domino: #115 bbb could not be rebased onto main because of conflicts, run: git rebase --onto origin/main 2e6584b feature-b\x07",
+		ProtocolOSC777: "\x1b]777;notify;gh-domino;#115 bbb could not be rebased onto main because of conflicts, run: git rebase --onto origin/main 2e6584b feature-b\x07",
+	} {
+		out := &bytes.Buffer{}
+		assert.NoError(t, NewTerminal(out, protocol).Notify(t.Context(), conflict))
+		assert.Equal(t, want, out.String(), protocol)
+	}
+
+	// A title can't end the escape sequence early.
+	n := conflict
+	n.Title = "bbb\x07\x1b]9;injected"
+	out := &bytes.Buffer{}
+	assert.NoError(t, NewTerminal(out, ProtocolOSC9).Notify(t.Context(), n))
+	assert.Equal(t, 1, strings.Count(out.String(), "\x07"), "control characters were not escaped: %q", out.String())
+	assert.Equal(t, 1, strings.Count(out.String(), "\x1b"), "control characters were not escaped: %q", out.String())
+}
+
+func TestWebhook(t *testing.T) {
+	var got Notification
+	var contentType string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		contentType = r.Header.Get("Content-Type")
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&got))
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	assert.NoError(t, NewWebhook(server.URL).Notify(t.Context(), conflict))
+	assert.Equal(t, "application/json", contentType)
+	assert.Equal(t, conflict, got)
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer failing.Close()
+	assert.ErrorContains(t, NewWebhook(failing.URL).Notify(t.Context(), conflict), "500 Internal Server Error")
+}
+
+func TestCommand(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh is not installed")
+	}
+
+	out := filepath.Join(t.TempDir(), "out")
+	command := `printf '%s\n' "$DOMINO_EVENT" "$DOMINO_PR_NUMBER" "$DOMINO_COMMAND" > "$OUT" && cat >> "$OUT"`
+	t.Setenv("OUT", out)
+	if err := NewCommand(command).Notify(t.Context(), conflict); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.SplitN(string(data), "\n", 4)
+	if len(lines) != 4 {
+		t.Fatalf("unexpected output %q", data)
+	}
+	assert.Equal(t, []string{"rebase-conflict", "115", conflict.Command}, lines[:3])
+	var payload Notification
+	assert.NoError(t, json.Unmarshal([]byte(lines[3]), &payload))
+	assert.Equal(t, conflict, payload)
+
+	// The output of a failed command comes with the error.
+	assert.ErrorContains(t, NewCommand("echo oops >&2; exit 3").Notify(t.Context(), conflict), "oops")
+}
@@ -0,0 +1,324 @@
+package odb
+
+import (
+	"bytes"
+	"container/heap"
+	"fmt"
+	"strconv"
+)
+
+// Commit is what the walks need to know about a commit.
+type Commit struct {
+	Hash    Hash
+	Parents []Hash
+	// Time is the committer time, which orders the walks like git does.
+	Time int64
+}
+
+// commit returns the commit from the commit-graph if it is there, or from its object.
+// The caller holds the lock.
+func (r *Repository) commit(h Hash) (*Commit, error) {
+	if c, ok := r.commits[h]; ok {
+		return c, nil
+	}
+
+	var c *Commit
+	if r.graph != nil {
+		if pos, ok := r.graph.lookup(h); ok {
+			var err error
+			if c, err = r.graph.commit(pos); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if c == nil {
+		typ, data, err := r.readObject(h)
+		if err != nil {
+			return nil, err
+		}
+		if typ != objectCommit {
+			return nil, fmt.Errorf("%s is not a commit", h)
+		}
+		if c, err = parseCommit(h, data); err != nil {
+			return nil, err
+		}
+	}
+	r.commits[h] = c
+	return c, nil
+}
+
+func parseCommit(h Hash, data []byte) (*Commit, error) {
+	c := &Commit{Hash: h}
+	for len(data) > 0 {
+		line, rest, _ := bytes.Cut(data, []byte{'\n'})
+		data = rest
+		if len(line) == 0 {
+			// The headers end at the first empty line, where the message starts.
+			break
+		}
+		key, value, _ := bytes.Cut(line, []byte{' '})
+		switch string(key) {
+		case "parent":
+			parent, ok := ParseHash(string(value))
+			if !ok {
+				return nil, fmt.Errorf("invalid parent in commit %s", h)
+			}
+			c.Parents = append(c.Parents, parent)
+		case "committer":
+			// Name <email> timestamp timezone
+			fields := bytes.Fields(value[bytes.LastIndexByte(value, '>')+1:])
+			if len(fields) < 1 {
+				return nil, fmt.Errorf("invalid committer in commit %s", h)
+			}
+			t, err := strconv.ParseInt(string(fields[0]), 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid committer time in commit %s", h)
+			}
+			c.Time = t
+		}
+	}
+	return c, nil
+}
+
+// commitQueue pops the most recent commits first, and the first pushed ones among those at the same time.
+type commitQueue struct {
+	items []queuedCommit
+	seq   int
+}
+
+type queuedCommit struct {
+	commit *Commit
+	seq    int
+}
+
+func (q *commitQueue) Len() int { return len(q.items) }
+func (q *commitQueue) Less(i, j int) bool {
+	if q.items[i].commit.Time != q.items[j].commit.Time {
+		return q.items[i].commit.Time > q.items[j].commit.Time
+	}
+	return q.items[i].seq < q.items[j].seq
+}
+func (q *commitQueue) Swap(i, j int) { q.items[i], q.items[j] = q.items[j], q.items[i] }
+func (q *commitQueue) Push(x any)    { q.items = append(q.items, x.(queuedCommit)) }
+func (q *commitQueue) Pop() any {
+	item := q.items[len(q.items)-1]
+	q.items = q.items[:len(q.items)-1]
+	return item
+}
+
+func (q *commitQueue) push(c *Commit) {
+	heap.Push(q, queuedCommit{commit: c, seq: q.seq})
+	q.seq++
+}
+
+func (q *commitQueue) pop() *Commit {
+	return heap.Pop(q).(queuedCommit).commit
+}
+
+const (
+	flagParent1 = 1 << iota
+	flagParent2
+	flagStale
+	flagResult
+	flagUninteresting
+	flagSeen
+)
+
+// MergeBase returns the best common ancestor of the two commits, like git merge-base.
+// It reports ErrUnsupported when there are several of them, as git picks one of them by
+// rules which are not worth replicating here.
+func (r *Repository) MergeBase(a, b Hash) (Hash, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	bases, err := r.mergeBases(a, b)
+	if err != nil {
+		return Hash{}, err
+	}
+	switch len(bases) {
+	case 0:
+		return Hash{}, fmt.Errorf("no merge base between %s and %s", a, b)
+	case 1:
+		return bases[0], nil
+	default:
+		return Hash{}, fmt.Errorf("several merge bases are %w", ErrUnsupported)
+	}
+}
+
+// IsAncestor tells whether the ancestor commit is reachable from the descendant one.
+func (r *Repository) IsAncestor(ancestor, descendant Hash) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if ancestor == descendant {
+		return true, nil
+	}
+	bases, err := r.mergeBases(ancestor, descendant)
+	if err != nil {
+		return false, err
+	}
+	for _, base := range bases {
+		if base == ancestor {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// mergeBases paints the ancestors of both commits down to their common ones, from the most recent,
+// and returns the common ones which are not an ancestor of another one.
+func (r *Repository) mergeBases(a, b Hash) ([]Hash, error) {
+	if a == b {
+		return []Hash{a}, nil
+	}
+
+	flags := make(map[Hash]int)
+	q := &commitQueue{}
+	for _, start := range []struct {
+		hash Hash
+		flag int
+	}{{a, flagParent1}, {b, flagParent2}} {
+		c, err := r.commit(start.hash)
+		if err != nil {
+			return nil, err
+		}
+		flags[start.hash] |= start.flag
+		q.push(c)
+	}
+
+	var results []Hash
+	for hasNonStale(q, flags) {
+		c := q.pop()
+		f := flags[c.Hash] & (flagParent1 | flagParent2 | flagStale)
+		if f == flagParent1|flagParent2 {
+			if flags[c.Hash]&flagResult == 0 {
+				flags[c.Hash] |= flagResult
+				results = append(results, c.Hash)
+			}
+			// The ancestors of a common commit can't be the best ones.
+			f |= flagStale
+		}
+		for _, p := range c.Parents {
+			if flags[p]&f == f {
+				continue
+			}
+			parent, err := r.commit(p)
+			if err != nil {
+				return nil, err
+			}
+			flags[p] |= f
+			q.push(parent)
+		}
+	}
+
+	var bases []Hash
+	for _, h := range results {
+		if flags[h]&flagStale == 0 {
+			bases = append(bases, h)
+		}
+	}
+	return bases, nil
+}
+
+func hasNonStale(q *commitQueue, flags map[Hash]int) bool {
+	for _, item := range q.items {
+		if flags[item.commit.Hash]&flagStale == 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// walkSlop is how many more commits are walked once only uninteresting ones are left,
+// to make up for commits with a wrong time, as git does.
+const walkSlop = 5
+
+// RevList returns the commits reachable from include but not from exclude, like git log exclude..include,
+// from the most recent one.
+func (r *Repository) RevList(exclude, include Hash) ([]Hash, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	flags := make(map[Hash]int)
+	q := &commitQueue{}
+	for _, start := range []struct {
+		hash Hash
+		flag int
+	}{{include, flagSeen}, {exclude, flagSeen | flagUninteresting}} {
+		c, err := r.commit(start.hash)
+		if err != nil {
+			return nil, err
+		}
+		if flags[start.hash]&flagSeen == 0 {
+			q.push(c)
+		}
+		flags[start.hash] |= start.flag
+	}
+
+	var walked []Hash
+	slop := walkSlop
+	for q.Len() > 0 {
+		if onlyUninteresting(q, flags) {
+			if slop == 0 {
+				break
+			}
+			slop--
+		} else {
+			slop = walkSlop
+		}
+
+		c := q.pop()
+		uninteresting := flags[c.Hash]&flagUninteresting != 0
+		if !uninteresting {
+			walked = append(walked, c.Hash)
+		}
+		for _, p := range c.Parents {
+			if uninteresting {
+				r.markUninteresting(p, flags)
+			}
+			if flags[p]&flagSeen != 0 {
+				continue
+			}
+			parent, err := r.commit(p)
+			if err != nil {
+				return nil, err
+			}
+			flags[p] |= flagSeen
+			q.push(parent)
+		}
+	}
+
+	// Some commits may have been walked before they were found to be reachable from exclude.
+	var commits []Hash
+	for _, h := range walked {
+		if flags[h]&flagUninteresting == 0 {
+			commits = append(commits, h)
+		}
+	}
+	return commits, nil
+}
+
+// markUninteresting marks the commit as uninteresting, along with its ancestors already walked.
+func (r *Repository) markUninteresting(h Hash, flags map[Hash]int) {
+	stack := []Hash{h}
+	for len(stack) > 0 {
+		h := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if flags[h]&flagUninteresting != 0 {
+			continue
+		}
+		flags[h] |= flagUninteresting
+		if c, ok := r.commits[h]; ok && flags[h]&flagSeen != 0 {
+			stack = append(stack, c.Parents...)
+		}
+	}
+}
+
+func onlyUninteresting(q *commitQueue, flags map[Hash]int) bool {
+	for _, item := range q.items {
+		if flags[item.commit.Hash]&flagUninteresting == 0 {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,194 @@
+package odb
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// commitGraph is the commit-graph file of the repository, or the chain of its layers from the base one,
+// which holds the parents and the commit time of the commits without reading their objects.
+type commitGraph struct {
+	layers []*graphLayer
+}
+
+type graphLayer struct {
+	count int
+	// base is the number of commits in the layers below, where the positions of this layer start.
+	base   int
+	fanout []byte
+	oids   []byte
+	data   []byte
+	edges  []byte
+}
+
+const (
+	graphParentNone    = 0x70000000
+	graphParentOctopus = 0x80000000
+	graphDataSize      = 20 + 4 + 4 + 8
+)
+
+func openCommitGraph(objectsDir string) (*commitGraph, error) {
+	infoDir := filepath.Join(objectsDir, "info")
+	if _, err := os.Stat(filepath.Join(infoDir, "commit-graph")); err == nil {
+		layer, err := readGraphLayer(filepath.Join(infoDir, "commit-graph"), 0)
+		if err != nil {
+			return nil, err
+		}
+		return &commitGraph{layers: []*graphLayer{layer}}, nil
+	}
+
+	chain, err := os.ReadFile(filepath.Join(infoDir, "commit-graphs", "commit-graph-chain"))
+	if err != nil {
+		return nil, err
+	}
+	g := &commitGraph{}
+	base := 0
+	for _, hash := range strings.Fields(string(chain)) {
+		layer, err := readGraphLayer(filepath.Join(infoDir, "commit-graphs", "graph-"+hash+".graph"), base)
+		if err != nil {
+			return nil, err
+		}
+		g.layers = append(g.layers, layer)
+		base += layer.count
+	}
+	return g, nil
+}
+
+func readGraphLayer(path string, base int) (*graphLayer, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < 8 || !bytes.Equal(data[:4], []byte("CGPH")) || data[4] != 1 || data[5] != 1 {
+		return nil, fmt.Errorf("commit-graph %s is %w", path, ErrUnsupported)
+	}
+
+	chunkCount := int(data[6])
+	if len(data) < 8+(chunkCount+1)*12 {
+		return nil, fmt.Errorf("truncated commit-graph %s", path)
+	}
+	chunks := make(map[string][]byte)
+	for i := 0; i < chunkCount; i++ {
+		entry := data[8+i*12:]
+		next := data[8+(i+1)*12:]
+		start, end := binary.BigEndian.Uint64(entry[4:12]), binary.BigEndian.Uint64(next[4:12])
+		if start > end || end > uint64(len(data)) {
+			return nil, fmt.Errorf("invalid chunk in commit-graph %s", path)
+		}
+		chunks[string(entry[:4])] = data[start:end]
+	}
+
+	layer := &graphLayer{
+		base:   base,
+		fanout: chunks["OIDF"],
+		oids:   chunks["OIDL"],
+		data:   chunks["CDAT"],
+		edges:  chunks["EDGE"],
+	}
+	if len(layer.fanout) != 256*4 {
+		return nil, fmt.Errorf("invalid fanout in commit-graph %s", path)
+	}
+	layer.count = int(binary.BigEndian.Uint32(layer.fanout[255*4:]))
+	if len(layer.oids) != layer.count*20 || len(layer.data) != layer.count*graphDataSize {
+		return nil, fmt.Errorf("invalid commit-graph %s", path)
+	}
+	return layer, nil
+}
+
+// lookup returns the position of the commit in the graph.
+func (g *commitGraph) lookup(h Hash) (int, bool) {
+	for _, layer := range g.layers {
+		lo := 0
+		if h[0] > 0 {
+			lo = int(binary.BigEndian.Uint32(layer.fanout[(int(h[0])-1)*4:]))
+		}
+		hi := int(binary.BigEndian.Uint32(layer.fanout[int(h[0])*4:]))
+		for lo < hi {
+			mid := (lo + hi) / 2
+			switch cmp := bytes.Compare(layer.oids[mid*20:mid*20+20], h[:]); {
+			case cmp == 0:
+				return layer.base + mid, true
+			case cmp < 0:
+				lo = mid + 1
+			default:
+				hi = mid
+			}
+		}
+	}
+	return 0, false
+}
+
+func (g *commitGraph) layerAt(pos int) (*graphLayer, int, error) {
+	for _, layer := range g.layers {
+		if pos >= layer.base && pos < layer.base+layer.count {
+			return layer, pos - layer.base, nil
+		}
+	}
+	return nil, 0, fmt.Errorf("invalid position %d in commit-graph", pos)
+}
+
+func (g *commitGraph) hashAt(pos int) (Hash, error) {
+	layer, i, err := g.layerAt(pos)
+	if err != nil {
+		return Hash{}, err
+	}
+	var h Hash
+	copy(h[:], layer.oids[i*20:])
+	return h, nil
+}
+
+// commit reads the parents and the commit time of the commit at the position.
+func (g *commitGraph) commit(pos int) (*Commit, error) {
+	layer, i, err := g.layerAt(pos)
+	if err != nil {
+		return nil, err
+	}
+	entry := layer.data[i*graphDataSize:]
+
+	c := &Commit{}
+	copy(c.Hash[:], layer.oids[i*20:])
+	genAndTime := binary.BigEndian.Uint32(entry[28:32])
+	c.Time = int64(genAndTime&0x3)<<32 | int64(binary.BigEndian.Uint32(entry[32:36]))
+
+	parent := func(p uint32) error {
+		h, err := g.hashAt(int(p))
+		if err != nil {
+			return err
+		}
+		c.Parents = append(c.Parents, h)
+		return nil
+	}
+
+	first, second := binary.BigEndian.Uint32(entry[20:24]), binary.BigEndian.Uint32(entry[24:28])
+	if first != graphParentNone {
+		if err := parent(first); err != nil {
+			return nil, err
+		}
+	}
+	switch {
+	case second == graphParentNone:
+	case second&graphParentOctopus == 0:
+		if err := parent(second); err != nil {
+			return nil, err
+		}
+	default:
+		// The other parents of an octopus merge are listed in the extra edges, the last one flagged.
+		for edge := int(second &^ graphParentOctopus); ; edge++ {
+			if (edge+1)*4 > len(layer.edges) {
+				return nil, fmt.Errorf("invalid extra edge in commit-graph")
+			}
+			p := binary.BigEndian.Uint32(layer.edges[edge*4:])
+			if err := parent(p &^ graphParentOctopus); err != nil {
+				return nil, err
+			}
+			if p&graphParentOctopus != 0 {
+				break
+			}
+		}
+	}
+	return c, nil
+}
@@ -0,0 +1,200 @@
+package odb
+
+import (
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+const (
+	objectCommit   = 1
+	objectTree     = 2
+	objectBlob     = 3
+	objectTag      = 4
+	objectOfsDelta = 6
+	objectRefDelta = 7
+)
+
+var errObjectNotFound = errors.New("object not found")
+
+// readObject returns the type and the content of the object, from its loose file or a pack.
+// Packs are rescanned once if it is not found, since a fetch may have added one meanwhile.
+func (r *Repository) readObject(h Hash) (int, []byte, error) {
+	for _, dir := range r.objectDirs {
+		typ, data, err := readLooseObject(dir, h)
+		if err == nil {
+			return typ, data, nil
+		}
+		if !errors.Is(err, os.ErrNotExist) {
+			return 0, nil, err
+		}
+	}
+
+	for _, rescan := range []bool{false, true} {
+		if rescan || !r.packsLoaded {
+			if err := r.loadPacks(); err != nil {
+				return 0, nil, err
+			}
+		}
+		for _, p := range r.packs {
+			if offset, ok := p.find(h); ok {
+				return r.readPackedObject(p, offset)
+			}
+		}
+	}
+	return 0, nil, fmt.Errorf("%w: %s", errObjectNotFound, h)
+}
+
+func readLooseObject(objectsDir string, h Hash) (int, []byte, error) {
+	hex := h.String()
+	f, err := os.Open(filepath.Join(objectsDir, hex[:2], hex[2:]))
+	if err != nil {
+		return 0, nil, err
+	}
+	defer f.Close() //nolint:errcheck
+
+	zr, err := zlib.NewReader(f)
+	if err != nil {
+		return 0, nil, fmt.Errorf("invalid loose object %s: %w", hex, err)
+	}
+	defer zr.Close() //nolint:errcheck
+	raw, err := io.ReadAll(zr)
+	if err != nil {
+		return 0, nil, fmt.Errorf("invalid loose object %s: %w", hex, err)
+	}
+
+	header, data, ok := bytes.Cut(raw, []byte{0})
+	if !ok {
+		return 0, nil, fmt.Errorf("invalid loose object %s", hex)
+	}
+	name, size, ok := bytes.Cut(header, []byte{' '})
+	if !ok {
+		return 0, nil, fmt.Errorf("invalid loose object %s", hex)
+	}
+	if n, err := strconv.Atoi(string(size)); err != nil || n != len(data) {
+		return 0, nil, fmt.Errorf("invalid size of loose object %s", hex)
+	}
+
+	switch string(name) {
+	case "commit":
+		return objectCommit, data, nil
+	case "tree":
+		return objectTree, data, nil
+	case "blob":
+		return objectBlob, data, nil
+	case "tag":
+		return objectTag, data, nil
+	default:
+		return 0, nil, fmt.Errorf("invalid type of loose object %s", hex)
+	}
+}
+
+func (r *Repository) loadPacks() error {
+	for _, p := range r.packs {
+		_ = p.close()
+	}
+	r.packs = nil
+
+	for _, dir := range r.objectDirs {
+		indexes, err := filepath.Glob(filepath.Join(dir, "pack", "*.idx"))
+		if err != nil {
+			return err
+		}
+		for _, index := range indexes {
+			p, err := openPack(index)
+			if err != nil {
+				return err
+			}
+			r.packs = append(r.packs, p)
+		}
+	}
+	r.packsLoaded = true
+	return nil
+}
+
+// applyDelta rebuilds an object from its base and a delta of the pack format.
+func applyDelta(base, delta []byte) ([]byte, error) {
+	errInvalid := errors.New("invalid delta")
+
+	readSize := func() (int, error) {
+		size, shift := 0, 0
+		for {
+			if len(delta) == 0 {
+				return 0, errInvalid
+			}
+			b := delta[0]
+			delta = delta[1:]
+			size |= int(b&0x7f) << shift
+			shift += 7
+			if b&0x80 == 0 {
+				return size, nil
+			}
+		}
+	}
+
+	baseSize, err := readSize()
+	if err != nil {
+		return nil, err
+	}
+	if baseSize != len(base) {
+		return nil, errInvalid
+	}
+	size, err := readSize()
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]byte, 0, size)
+	for len(delta) > 0 {
+		op := delta[0]
+		delta = delta[1:]
+		switch {
+		case op&0x80 != 0:
+			// Copy a range of the base.
+			var offset, n int
+			for i := 0; i < 4; i++ {
+				if op&(1<<i) != 0 {
+					if len(delta) == 0 {
+						return nil, errInvalid
+					}
+					offset |= int(delta[0]) << (8 * i)
+					delta = delta[1:]
+				}
+			}
+			for i := 0; i < 3; i++ {
+				if op&(0x10<<i) != 0 {
+					if len(delta) == 0 {
+						return nil, errInvalid
+					}
+					n |= int(delta[0]) << (8 * i)
+					delta = delta[1:]
+				}
+			}
+			if n == 0 {
+				n = 0x10000
+			}
+			if offset+n > len(base) {
+				return nil, errInvalid
+			}
+			out = append(out, base[offset:offset+n]...)
+		case op != 0:
+			// Insert the next bytes of the delta.
+			if int(op) > len(delta) {
+				return nil, errInvalid
+			}
+			out = append(out, delta[:op]...)
+			delta = delta[op:]
+		default:
+			return nil, errInvalid
+		}
+	}
+	if len(out) != size {
+		return nil, errInvalid
+	}
+	return out, nil
+}
@@ -0,0 +1,267 @@
+package odb
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testRepo struct {
+	t    *testing.T
+	dir  string
+	time int
+}
+
+func newTestRepo(t *testing.T) *testRepo {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	r := &testRepo{t: t, dir: t.TempDir(), time: 1700000000}
+	r.git("init", "--quiet", "--initial-branch=main")
+	return r
+}
+
+func (r *testRepo) git(args ...string) string {
+	r.t.Helper()
+	cmd := exec.Command("git", args...)
+	cmd.Dir = r.dir
+	date := fmt.Sprintf("%d +0000", r.time)
+	cmd.Env = append(os.Environ(),
+		"GIT_AUTHOR_NAME=test", "GIT_AUTHOR_EMAIL=test@example.com", "GIT_AUTHOR_DATE="+date,
+		"GIT_COMMITTER_NAME=test", "GIT_COMMITTER_EMAIL=test@example.com", "GIT_COMMITTER_DATE="+date,
+		"GIT_CONFIG_NOSYSTEM=1", "HOME="+r.dir,
+	)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		r.t.Fatalf("git %s: %v\n%s", strings.Join(args, " "), err, out)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// commit commits a change of the file, at the same time as the previous commit if tie is set.
+func (r *testRepo) commit(file, content string, tie bool) {
+	r.t.Helper()
+	if !tie {
+		r.time += 60
+	}
+	if err := os.WriteFile(filepath.Join(r.dir, file), []byte(content), 0o644); err != nil {
+		r.t.Fatal(err)
+	}
+	r.git("add", file)
+	r.git("commit", "--quiet", "-m", file+": "+content)
+}
+
+// buildHistory makes branches with merges, and commits at the same time:
+//
+//	main:    A - B - C ------- M - N
+//	              \           /
+//	feature:       D - E - F
+//	                \
+//	topic:           G - H (merged from main at I)
+func buildHistory(r *testRepo) []string {
+	r.commit("a.txt", "A", false)
+	r.commit("b.txt", "B", false)
+	r.git("branch", "feature")
+	r.commit("c.txt", "C", false)
+
+	r.git("checkout", "--quiet", "feature")
+	r.commit("d.txt", "D", false)
+	r.git("branch", "topic")
+	r.commit("e.txt", "E", true)
+	r.commit("f.txt", "F", false)
+
+	r.git("checkout", "--quiet", "topic")
+	r.commit("g.txt", "G", false)
+	r.commit("h.txt", "H", true)
+	r.time += 60
+	r.git("merge", "--quiet", "--no-edit", "main")
+
+	r.git("checkout", "--quiet", "main")
+	r.time += 60
+	r.git("merge", "--quiet", "--no-edit", "feature")
+	r.commit("n.txt", "N", false)
+
+	r.git("update-ref", "refs/remotes/origin/main", "main~1")
+	r.git("symbolic-ref", "refs/remotes/origin/HEAD", "refs/remotes/origin/main")
+	r.git("tag", "v1", "main~2")
+
+	return []string{"main", "feature", "topic", "origin/main", "origin", "v1", "HEAD", "main~1", "feature~2", "topic^"}
+}
+
+func (r *testRepo) open() *Repository {
+	r.t.Helper()
+	repo, err := Open(r.dir)
+	if err != nil {
+		r.t.Fatal(err)
+	}
+	return repo
+}
+
+// compare checks every query against the git CLI for each pair of revisions.
+func compare(t *testing.T, r *testRepo, revs []string) {
+	repo := r.open()
+
+	hashes := make(map[string]Hash)
+	for _, rev := range revs {
+		want := r.git("rev-parse", rev)
+		h, ok := ParseHash(want)
+		if !ok {
+			t.Fatalf("invalid rev-parse output %q", want)
+		}
+		hashes[rev] = h
+
+		got, err := repo.RevParse(rev)
+		if strings.ContainsAny(rev, "^~") {
+			assert.Error(t, err, "RevParse(%s) should not be supported", rev)
+			continue
+		}
+		if assert.NoError(t, err, "RevParse(%s)", rev) {
+			assert.Equal(t, h, got, "RevParse(%s)", rev)
+		}
+	}
+
+	for _, a := range revs {
+		for _, b := range revs {
+			ha, hb := hashes[a], hashes[b]
+
+			wantBases := strings.Fields(r.git("merge-base", "--all", a, b))
+			base, err := repo.MergeBase(ha, hb)
+			if len(wantBases) == 1 {
+				if assert.NoError(t, err, "MergeBase(%s, %s)", a, b) {
+					assert.Equal(t, wantBases[0], base.String(), "MergeBase(%s, %s)", a, b)
+				}
+			} else {
+				assert.Error(t, err, "MergeBase(%s, %s) with the merge bases %v", a, b, wantBases)
+			}
+
+			cmd := exec.Command("git", "merge-base", "--is-ancestor", a, b)
+			cmd.Dir = r.dir
+			wantAncestor := cmd.Run() == nil
+			got, err := repo.IsAncestor(ha, hb)
+			if assert.NoError(t, err, "IsAncestor(%s, %s)", a, b) {
+				assert.Equal(t, wantAncestor, got, "IsAncestor(%s, %s)", a, b)
+			}
+
+			wantLog := strings.Fields(r.git("log", "--pretty=%H", a+".."+b))
+			commits, err := repo.RevList(ha, hb)
+			if !assert.NoError(t, err, "RevList(%s, %s)", a, b) {
+				continue
+			}
+			gotLog := make([]string, 0, len(commits))
+			for _, c := range commits {
+				gotLog = append(gotLog, c.String())
+			}
+			assert.Equal(t, wantLog, gotLog, "RevList(%s, %s)", a, b)
+		}
+	}
+}
+
+func TestLooseObjects(t *testing.T) {
+	r := newTestRepo(t)
+	revs := buildHistory(r)
+	compare(t, r, revs)
+}
+
+func TestPackedObjects(t *testing.T) {
+	r := newTestRepo(t)
+	revs := buildHistory(r)
+	r.git("repack", "-a", "-d", "-f", "--depth=50", "--window=250", "--quiet")
+	r.git("pack-refs", "--all")
+	r.git("prune-packed")
+	compare(t, r, revs)
+}
+
+func TestCommitGraph(t *testing.T) {
+	r := newTestRepo(t)
+	revs := buildHistory(r)
+	r.git("commit-graph", "write", "--reachable")
+	compare(t, r, revs)
+}
+
+func TestCommitGraphChain(t *testing.T) {
+	r := newTestRepo(t)
+	r.commit("z.txt", "Z", false)
+	r.git("commit-graph", "write", "--reachable", "--split")
+	revs := buildHistory(r)
+	r.git("commit-graph", "write", "--reachable", "--split=no-merge")
+	if _, err := os.Stat(filepath.Join(r.dir, ".git", "objects", "info", "commit-graphs", "commit-graph-chain")); err != nil {
+		t.Fatalf("no commit-graph chain: %v", err)
+	}
+	compare(t, r, revs)
+}
+
+func TestWorktree(t *testing.T) {
+	r := newTestRepo(t)
+	buildHistory(r)
+	worktree := filepath.Join(t.TempDir(), "worktree")
+	r.git("worktree", "add", "--quiet", "--detach", worktree, "feature")
+
+	repo, err := Open(worktree)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := repo.RevParse("HEAD")
+	assert.NoError(t, err)
+	assert.Equal(t, r.git("rev-parse", "feature"), got.String(), "HEAD should be the head of feature")
+	got, err = repo.RevParse("main")
+	assert.NoError(t, err)
+	assert.Equal(t, r.git("rev-parse", "main"), got.String())
+}
+
+func TestUnknownRevision(t *testing.T) {
+	r := newTestRepo(t)
+	buildHistory(r)
+	repo := r.open()
+	_, err := repo.RevParse("unknown")
+	assert.Error(t, err)
+	_, err = repo.IsAncestor(Hash{1}, Hash{2})
+	assert.Error(t, err, "missing objects")
+}
+
+func TestPackedDeltas(t *testing.T) {
+	r := newTestRepo(t)
+	for i := 1; i <= 20; i++ {
+		lines := make([]string, 0, 100+i)
+		for j := 0; j < 100+i; j++ {
+			lines = append(lines, fmt.Sprintf("line %d", j))
+		}
+		r.commit("f.txt", strings.Join(lines, "\n"), false)
+	}
+	r.git("repack", "-a", "-d", "-f", "--depth=50", "--window=250", "--quiet")
+	r.git("prune-packed")
+
+	repo := r.open()
+	for _, line := range strings.Split(r.git("rev-list", "--objects", "--all"), "\n") {
+		sha, _, _ := strings.Cut(line, " ")
+		h, _ := ParseHash(sha)
+		_, data, err := repo.readObject(h)
+		if err != nil {
+			t.Fatalf("readObject(%s): %v", sha, err)
+		}
+		cmd := exec.Command("git", "cat-file", r.git("cat-file", "-t", sha), sha)
+		cmd.Dir = r.dir
+		want, err := cmd.Output()
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, string(want), string(data), "readObject(%s)", sha)
+	}
+
+	indexes, err := filepath.Glob(filepath.Join(r.dir, ".git", "objects", "pack", "*.idx"))
+	if err != nil || len(indexes) != 1 {
+		t.Fatalf("expected a single pack, got %v, %v", indexes, err)
+	}
+	deltas := 0
+	for _, line := range strings.Split(r.git("verify-pack", "-v", indexes[0]), "\n") {
+		if len(strings.Fields(line)) == 7 {
+			deltas++
+		}
+	}
+	assert.NotZero(t, deltas, "expected deltified objects in the pack")
+}
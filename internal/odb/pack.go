@@ -0,0 +1,148 @@
+package odb
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// pack is a packfile along with its version 2 index.
+type pack struct {
+	file  *os.File
+	index []byte
+	count int
+}
+
+const (
+	indexHeaderSize = 8
+	indexFanoutSize = 256 * 4
+)
+
+func openPack(indexPath string) (*pack, error) {
+	index, err := os.ReadFile(indexPath)
+	if err != nil {
+		return nil, err
+	}
+	if len(index) < indexHeaderSize+indexFanoutSize || !bytes.Equal(index[:4], []byte{0xff, 't', 'O', 'c'}) ||
+		binary.BigEndian.Uint32(index[4:8]) != 2 {
+		return nil, fmt.Errorf("pack index %s is %w", indexPath, ErrUnsupported)
+	}
+	count := int(binary.BigEndian.Uint32(index[indexHeaderSize+indexFanoutSize-4:]))
+	if len(index) < indexHeaderSize+indexFanoutSize+count*(20+4+4) {
+		return nil, fmt.Errorf("truncated pack index %s", indexPath)
+	}
+
+	file, err := os.Open(strings.TrimSuffix(indexPath, ".idx") + ".pack")
+	if err != nil {
+		return nil, err
+	}
+	return &pack{file: file, index: index, count: count}, nil
+}
+
+func (p *pack) close() error {
+	return p.file.Close()
+}
+
+// find returns the offset of the object in the pack.
+func (p *pack) find(h Hash) (int64, bool) {
+	fanout := p.index[indexHeaderSize:]
+	lo := 0
+	if h[0] > 0 {
+		lo = int(binary.BigEndian.Uint32(fanout[(int(h[0])-1)*4:]))
+	}
+	hi := int(binary.BigEndian.Uint32(fanout[int(h[0])*4:]))
+
+	names := p.index[indexHeaderSize+indexFanoutSize:]
+	for lo < hi {
+		mid := (lo + hi) / 2
+		switch cmp := bytes.Compare(names[mid*20:mid*20+20], h[:]); {
+		case cmp == 0:
+			return p.offset(mid), true
+		case cmp < 0:
+			lo = mid + 1
+		default:
+			hi = mid
+		}
+	}
+	return 0, false
+}
+
+func (p *pack) offset(i int) int64 {
+	offsets := p.index[indexHeaderSize+indexFanoutSize+p.count*(20+4):]
+	offset := binary.BigEndian.Uint32(offsets[i*4:])
+	if offset&0x80000000 == 0 {
+		return int64(offset)
+	}
+	// The offsets beyond 2GiB are in the table of 8-byte offsets.
+	large := offsets[p.count*4:]
+	return int64(binary.BigEndian.Uint64(large[int(offset&0x7fffffff)*8:]))
+}
+
+// readPackedObject reads the object at the offset of the pack, resolving the deltas.
+func (r *Repository) readPackedObject(p *pack, offset int64) (int, []byte, error) {
+	br := bufio.NewReader(io.NewSectionReader(p.file, offset, 1<<62))
+
+	b, err := br.ReadByte()
+	if err != nil {
+		return 0, nil, err
+	}
+	typ := int(b>>4) & 0x7
+	size := int64(b & 0x0f)
+	for shift := 4; b&0x80 != 0; shift += 7 {
+		if b, err = br.ReadByte(); err != nil {
+			return 0, nil, err
+		}
+		size |= int64(b&0x7f) << shift
+	}
+
+	var baseType int
+	var base []byte
+	switch typ {
+	case objectOfsDelta:
+		if b, err = br.ReadByte(); err != nil {
+			return 0, nil, err
+		}
+		distance := int64(b & 0x7f)
+		for b&0x80 != 0 {
+			if b, err = br.ReadByte(); err != nil {
+				return 0, nil, err
+			}
+			distance = ((distance + 1) << 7) | int64(b&0x7f)
+		}
+		if baseType, base, err = r.readPackedObject(p, offset-distance); err != nil {
+			return 0, nil, err
+		}
+	case objectRefDelta:
+		var baseHash Hash
+		if _, err := io.ReadFull(br, baseHash[:]); err != nil {
+			return 0, nil, err
+		}
+		if baseType, base, err = r.readObject(baseHash); err != nil {
+			return 0, nil, err
+		}
+	case objectCommit, objectTree, objectBlob, objectTag:
+	default:
+		return 0, nil, fmt.Errorf("invalid object type %d in pack", typ)
+	}
+
+	zr, err := zlib.NewReader(br)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer zr.Close() //nolint:errcheck
+	data := make([]byte, size)
+	if _, err := io.ReadFull(zr, data); err != nil {
+		return 0, nil, err
+	}
+
+	if typ != objectOfsDelta && typ != objectRefDelta {
+		return typ, data, nil
+	}
+	data, err = applyDelta(base, data)
+	return baseType, data, err
+}
@@ -0,0 +1,227 @@
+// Package odb reads the refs and the commits of a git repository in-process, for the read-only
+// queries which would otherwise fork a git process each, such as ancestry checks and merge bases.
+// It only reads SHA-1 repositories, and reports an error for anything it can't answer on its own,
+// so that the caller falls back to the git CLI.
+package odb
+
+import (
+	"bufio"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// ErrUnsupported is returned for the queries that can't be answered in-process.
+var ErrUnsupported = errors.New("not supported in-process")
+
+// Hash is the SHA-1 of a git object.
+type Hash [20]byte
+
+// ParseHash parses a full hexadecimal SHA-1.
+func ParseHash(s string) (Hash, bool) {
+	var h Hash
+	if len(s) != 2*len(h) {
+		return h, false
+	}
+	if _, err := hex.Decode(h[:], []byte(s)); err != nil {
+		return h, false
+	}
+	return h, true
+}
+
+func (h Hash) String() string {
+	return hex.EncodeToString(h[:])
+}
+
+// Repository is a git repository opened for reading.
+type Repository struct {
+	// gitDir is the git directory of the working tree, where its HEAD is.
+	gitDir string
+	// commonDir is where the refs and the objects shared by all the worktrees are.
+	commonDir  string
+	objectDirs []string
+
+	mu          sync.Mutex
+	packs       []*pack
+	packsLoaded bool
+	graph       *commitGraph
+	commits     map[Hash]*Commit
+}
+
+// Open opens the repository of the working tree containing dir, or the one of $GIT_DIR if set.
+func Open(dir string) (*Repository, error) {
+	gitDir := os.Getenv("GIT_DIR")
+	if gitDir == "" {
+		var err error
+		if gitDir, err = findGitDir(dir); err != nil {
+			return nil, err
+		}
+	}
+
+	commonDir := gitDir
+	if data, err := os.ReadFile(filepath.Join(gitDir, "commondir")); err == nil {
+		commonDir = strings.TrimSpace(string(data))
+		if !filepath.IsAbs(commonDir) {
+			commonDir = filepath.Join(gitDir, commonDir)
+		}
+	}
+
+	if config, err := os.ReadFile(filepath.Join(commonDir, "config")); err == nil {
+		if strings.Contains(strings.ToLower(string(config)), "objectformat = sha256") {
+			return nil, fmt.Errorf("SHA-256 repositories are %w", ErrUnsupported)
+		}
+	}
+
+	objectsDir := filepath.Join(commonDir, "objects")
+	r := &Repository{
+		gitDir:     gitDir,
+		commonDir:  commonDir,
+		objectDirs: append([]string{objectsDir}, readAlternates(objectsDir)...),
+		commits:    make(map[Hash]*Commit),
+	}
+	r.graph, _ = openCommitGraph(objectsDir)
+	return r, nil
+}
+
+func findGitDir(dir string) (string, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	for {
+		candidate := filepath.Join(dir, ".git")
+		info, err := os.Stat(candidate)
+		if err == nil && info.IsDir() {
+			return candidate, nil
+		}
+		if err == nil {
+			// A worktree or a submodule, pointing to its git directory.
+			data, err := os.ReadFile(candidate)
+			if err != nil {
+				return "", err
+			}
+			gitDir, ok := strings.CutPrefix(strings.TrimSpace(string(data)), "gitdir: ")
+			if !ok {
+				return "", fmt.Errorf("invalid .git file %s", candidate)
+			}
+			if !filepath.IsAbs(gitDir) {
+				gitDir = filepath.Join(dir, gitDir)
+			}
+			return gitDir, nil
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", fmt.Errorf("not a git repository")
+		}
+		dir = parent
+	}
+}
+
+func readAlternates(objectsDir string) []string {
+	data, err := os.ReadFile(filepath.Join(objectsDir, "info", "alternates"))
+	if err != nil {
+		return nil
+	}
+	var dirs []string
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if !filepath.IsAbs(line) {
+			line = filepath.Join(objectsDir, line)
+		}
+		dirs = append(dirs, line)
+	}
+	return dirs
+}
+
+// RevParse resolves a full SHA-1 or a ref name, such as a branch or a remote-tracking branch.
+// Revisions with an operator, such as main^2, are not supported.
+func (r *Repository) RevParse(rev string) (Hash, error) {
+	if h, ok := ParseHash(rev); ok {
+		return h, nil
+	}
+	if rev == "" || strings.ContainsAny(rev, "^~:@{}*?[\\ ") || strings.Contains(rev, "..") {
+		return Hash{}, fmt.Errorf("revision %q is %w", rev, ErrUnsupported)
+	}
+
+	// The same rules as git to tell which ref a short name stands for.
+	candidates := []string{
+		rev,
+		"refs/" + rev,
+		"refs/tags/" + rev,
+		"refs/heads/" + rev,
+		"refs/remotes/" + rev,
+		"refs/remotes/" + rev + "/HEAD",
+	}
+	if !strings.HasPrefix(rev, "refs/") && strings.ToUpper(rev) != rev {
+		// Only the pseudo refs like HEAD may be resolved from the root of the git directory.
+		candidates = candidates[1:]
+	}
+	for _, ref := range candidates {
+		h, err := r.readRef(ref, 0)
+		if err == nil {
+			return h, nil
+		}
+		if !errors.Is(err, os.ErrNotExist) {
+			return Hash{}, err
+		}
+	}
+	return Hash{}, fmt.Errorf("unknown revision %q", rev)
+}
+
+func (r *Repository) readRef(ref string, depth int) (Hash, error) {
+	if depth > 5 {
+		return Hash{}, fmt.Errorf("too many levels of symbolic refs for %s", ref)
+	}
+
+	for _, dir := range []string{r.gitDir, r.commonDir} {
+		data, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(ref)))
+		if err != nil {
+			continue
+		}
+		content := strings.TrimSpace(string(data))
+		if target, ok := strings.CutPrefix(content, "ref: "); ok {
+			return r.readRef(target, depth+1)
+		}
+		if h, ok := ParseHash(content); ok {
+			return h, nil
+		}
+		return Hash{}, fmt.Errorf("invalid ref %s", ref)
+	}
+	return r.readPackedRef(ref)
+}
+
+func (r *Repository) readPackedRef(ref string) (Hash, error) {
+	f, err := os.Open(filepath.Join(r.commonDir, "packed-refs"))
+	if err != nil {
+		return Hash{}, err
+	}
+	defer f.Close() //nolint:errcheck
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "#") || strings.HasPrefix(line, "^") {
+			continue
+		}
+		sha, name, ok := strings.Cut(line, " ")
+		if !ok || name != ref {
+			continue
+		}
+		if h, ok := ParseHash(sha); ok {
+			return h, nil
+		}
+		return Hash{}, fmt.Errorf("invalid packed ref %s", ref)
+	}
+	if err := scanner.Err(); err != nil {
+		return Hash{}, err
+	}
+	return Hash{}, os.ErrNotExist
+}
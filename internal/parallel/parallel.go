@@ -0,0 +1,41 @@
+package parallel
+
+import (
+	"context"
+	"sync"
+)
+
+// DefaultLimit is the number of git processes run at once by default.
+const DefaultLimit = 8
+
+// Map calls fn on each item with at most limit calls running at once, and returns the results
+// in the order of the items. On errors, it returns the one of the first failing item in that
+// order, so the outcome does not depend on the scheduling. A failure doesn't cancel the other calls
+// for the same reason.
+func Map[T, R any](ctx context.Context, items []T, limit int, fn func(ctx context.Context, item T) (R, error)) ([]R, error) {
+	if limit < 1 {
+		limit = 1
+	}
+
+	results := make([]R, len(items))
+	errs := make([]error, len(items))
+	sem := make(chan struct{}, limit)
+	var wg sync.WaitGroup
+	for i, item := range items {
+		sem <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			results[i], errs[i] = fn(ctx, item)
+		}()
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return results, err
+		}
+	}
+	return results, nil
+}
@@ -0,0 +1,52 @@
+package parallel
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMapKeepsOrder(t *testing.T) {
+	items := []int{5, 1, 4, 2, 3}
+	results, err := Map(context.Background(), items, 3, func(ctx context.Context, item int) (string, error) {
+		time.Sleep(time.Duration(item) * time.Millisecond)
+		return fmt.Sprint(item), nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"5", "1", "4", "2", "3"}, results)
+}
+
+func TestMapLimit(t *testing.T) {
+	var running, peak atomic.Int32
+	_, err := Map(context.Background(), make([]int, 20), 4, func(ctx context.Context, item int) (int, error) {
+		n := running.Add(1)
+		for {
+			p := peak.Load()
+			if n <= p || peak.CompareAndSwap(p, n) {
+				break
+			}
+		}
+		time.Sleep(time.Millisecond)
+		running.Add(-1)
+		return item, nil
+	})
+	assert.NoError(t, err)
+	assert.LessOrEqual(t, peak.Load(), int32(4), "too many calls at once")
+}
+
+func TestMapFirstError(t *testing.T) {
+	items := []int{1, 2, 3, 4}
+	_, err := Map(context.Background(), items, 4, func(ctx context.Context, item int) (int, error) {
+		if item >= 2 {
+			// The later items fail first.
+			time.Sleep(time.Duration(5-item) * time.Millisecond)
+			return 0, fmt.Errorf("item %d", item)
+		}
+		return item, nil
+	})
+	assert.EqualError(t, err, "item 2")
+}
@@ -0,0 +1,298 @@
+package progress
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/134130/gh-domino/gitobj"
+	"github.com/134130/gh-domino/internal/color"
+)
+
+type Kind int
+
+const (
+	// KindStarted starts a step, which lasts until the next succeeded or stopped event.
+	KindStarted Kind = iota
+	// KindLog is an output line of the running step.
+	KindLog
+	// KindSucceeded ends the running step successfully.
+	KindSucceeded
+	// KindStopped ends the running step on an error, which is reported on its own by the caller.
+	KindStopped
+	// KindFailed is a failure to report to the user.
+	KindFailed
+	// KindInfo is a text to show as is.
+	KindInfo
+)
+
+// Event is something that happened while running gh-domino.
+type Event interface {
+	Kind() Kind
+	Message() string
+}
+
+// Info is a text to show as is, such as the dependency tree.
+type Info struct {
+	Text string
+}
+
+func (e Info) Kind() Kind      { return KindInfo }
+func (e Info) Message() string { return e.Text }
+
+// Success is a successful outcome not bound to a step.
+type Success struct {
+	Text string
+}
+
+func (e Success) Kind() Kind      { return KindSucceeded }
+func (e Success) Message() string { return e.Text }
+
+// Failure is a failure to report to the user.
+type Failure struct {
+	Text string
+}
+
+func (e Failure) Kind() Kind      { return KindFailed }
+func (e Failure) Message() string { return e.Text }
+
+type FetchStarted struct{}
+
+func (e FetchStarted) Kind() Kind      { return KindStarted }
+func (e FetchStarted) Message() string { return "Fetching pull requests..." }
+
+// FetchLog is a command run while fetching, or a line of its output.
+type FetchLog struct {
+	Line string
+}
+
+func (e FetchLog) Kind() Kind      { return KindLog }
+func (e FetchLog) Message() string { return e.Line }
+
+type FetchDone struct{}
+
+func (e FetchDone) Kind() Kind      { return KindSucceeded }
+func (e FetchDone) Message() string { return "Fetching pull requests..." }
+
+type FetchFailed struct {
+	Err error
+}
+
+func (e FetchFailed) Kind() Kind      { return KindStopped }
+func (e FetchFailed) Message() string { return "Fetching pull requests..." }
+
+type RebaseStarted struct {
+	PR      gitobj.PullRequest
+	NewBase string
+}
+
+func (e RebaseStarted) Kind() Kind      { return KindStarted }
+func (e RebaseStarted) Message() string { return rebaseMessage(e.PR, e.NewBase) }
+
+type PRRebased struct {
+	PR      gitobj.PullRequest
+	NewBase string
+}
+
+func (e PRRebased) Kind() Kind      { return KindSucceeded }
+func (e PRRebased) Message() string { return rebaseMessage(e.PR, e.NewBase) }
+
+type RebaseFailed struct {
+	PR      gitobj.PullRequest
+	NewBase string
+	Err     error
+}
+
+func (e RebaseFailed) Kind() Kind      { return KindStopped }
+func (e RebaseFailed) Message() string { return rebaseMessage(e.PR, e.NewBase) }
+
+func rebaseMessage(pr gitobj.PullRequest, newBase string) string {
+	return fmt.Sprintf("Rebasing %s onto %s...", pr.String(), color.Cyan(newBase))
+}
+
+// PushStarted starts the push of the PRs, which is a single atomic push when there are several of them.
+type PushStarted struct {
+	PRs []gitobj.PullRequest
+}
+
+func (e PushStarted) Kind() Kind      { return KindStarted }
+func (e PushStarted) Message() string { return pushMessage(e.PRs) }
+
+type PRPushed struct {
+	PRs []gitobj.PullRequest
+}
+
+func (e PRPushed) Kind() Kind      { return KindSucceeded }
+func (e PRPushed) Message() string { return pushMessage(e.PRs) }
+
+type PushFailed struct {
+	PRs []gitobj.PullRequest
+	Err error
+}
+
+func (e PushFailed) Kind() Kind      { return KindStopped }
+func (e PushFailed) Message() string { return pushMessage(e.PRs) }
+
+func pushMessage(prs []gitobj.PullRequest) string {
+	if len(prs) == 1 {
+		return fmt.Sprintf("Pushing %s...", prs[0].PRNumberString())
+	}
+	prNumbers := make([]string, 0, len(prs))
+	for _, pr := range prs {
+		prNumbers = append(prNumbers, pr.PRNumberString())
+	}
+	return fmt.Sprintf("Pushing %s atomically...", strings.Join(prNumbers, ", "))
+}
+
+type BaseUpdateStarted struct {
+	PR      gitobj.PullRequest
+	NewBase string
+}
+
+func (e BaseUpdateStarted) Kind() Kind      { return KindStarted }
+func (e BaseUpdateStarted) Message() string { return baseUpdateMessage(e.PR, e.NewBase) }
+
+type BaseUpdated struct {
+	PR      gitobj.PullRequest
+	NewBase string
+}
+
+func (e BaseUpdated) Kind() Kind      { return KindSucceeded }
+func (e BaseUpdated) Message() string { return baseUpdateMessage(e.PR, e.NewBase) }
+
+type BaseUpdateFailed struct {
+	PR      gitobj.PullRequest
+	NewBase string
+	Err     error
+}
+
+func (e BaseUpdateFailed) Kind() Kind      { return KindStopped }
+func (e BaseUpdateFailed) Message() string { return baseUpdateMessage(e.PR, e.NewBase) }
+
+func baseUpdateMessage(pr gitobj.PullRequest, newBase string) string {
+	return fmt.Sprintf("Updating base branch of %s to %s...", pr.PRNumberString(), color.Cyan(newBase))
+}
+
+type CloseStarted struct {
+	PR gitobj.PullRequest
+}
+
+func (e CloseStarted) Kind() Kind      { return KindStarted }
+func (e CloseStarted) Message() string { return closeMessage(e.PR) }
+
+type PRClosed struct {
+	PR gitobj.PullRequest
+}
+
+func (e PRClosed) Kind() Kind      { return KindSucceeded }
+func (e PRClosed) Message() string { return closeMessage(e.PR) }
+
+type CloseFailed struct {
+	PR  gitobj.PullRequest
+	Err error
+}
+
+func (e CloseFailed) Kind() Kind      { return KindStopped }
+func (e CloseFailed) Message() string { return closeMessage(e.PR) }
+
+func closeMessage(pr gitobj.PullRequest) string {
+	return fmt.Sprintf("Closing %s as it has no changes left after the rebase...", pr.PRNumberString())
+}
+
+type DeleteBranchStarted struct {
+	Branch string
+}
+
+func (e DeleteBranchStarted) Kind() Kind      { return KindStarted }
+func (e DeleteBranchStarted) Message() string { return deleteBranchMessage(e.Branch) }
+
+type BranchDeleted struct {
+	Branch string
+}
+
+func (e BranchDeleted) Kind() Kind      { return KindSucceeded }
+func (e BranchDeleted) Message() string { return deleteBranchMessage(e.Branch) }
+
+type DeleteBranchFailed struct {
+	Branch string
+	Err    error
+}
+
+func (e DeleteBranchFailed) Kind() Kind      { return KindStopped }
+func (e DeleteBranchFailed) Message() string { return deleteBranchMessage(e.Branch) }
+
+func deleteBranchMessage(branch string) string {
+	return fmt.Sprintf("Deleting branch %s...", color.Blue(branch))
+}
+
+// HookStarted starts a hook command, around the steps of the PR if there is one.
+type HookStarted struct {
+	Hook string
+	PR   *gitobj.PullRequest
+}
+
+func (e HookStarted) Kind() Kind      { return KindStarted }
+func (e HookStarted) Message() string { return hookMessage(e.Hook, e.PR) }
+
+type HookDone struct {
+	Hook string
+	PR   *gitobj.PullRequest
+}
+
+func (e HookDone) Kind() Kind      { return KindSucceeded }
+func (e HookDone) Message() string { return hookMessage(e.Hook, e.PR) }
+
+type HookFailed struct {
+	Hook string
+	PR   *gitobj.PullRequest
+	Err  error
+}
+
+func (e HookFailed) Kind() Kind      { return KindStopped }
+func (e HookFailed) Message() string { return hookMessage(e.Hook, e.PR) }
+
+func hookMessage(hook string, pr *gitobj.PullRequest) string {
+	if pr == nil {
+		return fmt.Sprintf("Running %s hook...", hook)
+	}
+	return fmt.Sprintf("Running %s hook for %s...", hook, pr.PRNumberString())
+}
+
+// ExecStarted starts the verification command on the rebased PR, at its tip if the commit is empty.
+type ExecStarted struct {
+	PR     gitobj.PullRequest
+	Commit string
+}
+
+func (e ExecStarted) Kind() Kind      { return KindStarted }
+func (e ExecStarted) Message() string { return execMessage(e.PR, e.Commit) }
+
+type ExecDone struct {
+	PR     gitobj.PullRequest
+	Commit string
+}
+
+func (e ExecDone) Kind() Kind      { return KindSucceeded }
+func (e ExecDone) Message() string { return execMessage(e.PR, e.Commit) }
+
+type ExecFailed struct {
+	PR     gitobj.PullRequest
+	Commit string
+	Err    error
+}
+
+func (e ExecFailed) Kind() Kind      { return KindStopped }
+func (e ExecFailed) Message() string { return execMessage(e.PR, e.Commit) }
+
+func execMessage(pr gitobj.PullRequest, commit string) string {
+	if commit == "" {
+		return fmt.Sprintf("Verifying %s...", pr.PRNumberString())
+	}
+	return fmt.Sprintf("Verifying %s at %s...", pr.PRNumberString(), color.Yellow(shortSha(commit)))
+}
+
+func shortSha(sha string) string {
+	if len(sha) > 7 {
+		return sha[:7]
+	}
+	return sha
+}
@@ -0,0 +1,109 @@
+package progress
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"golang.org/x/term"
+
+	"github.com/134130/gh-domino/internal/color"
+)
+
+// Reporter renders the events reported by all the phases of a run.
+type Reporter interface {
+	Report(e Event)
+	// Close ends the running step, if any.
+	Close()
+}
+
+// New returns a reporter rendering a live view when w is a terminal,
+// and plain lines otherwise.
+func New(ctx context.Context, cancel context.CancelFunc, w io.Writer) Reporter {
+	if IsTerminal(w) {
+		return newTTYReporter(ctx, cancel, w)
+	}
+	return NewPlain(w)
+}
+
+// IsTerminal tells whether w is a terminal, where a live view can be rendered.
+func IsTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	return ok && term.IsTerminal(int(f.Fd()))
+}
+
+// Discard returns a reporter ignoring all the events.
+func Discard() Reporter {
+	return discard{}
+}
+
+type discard struct{}
+
+func (discard) Report(Event) {}
+func (discard) Close()       {}
+
+// Tee returns a reporter passing each event on to all the reporters, in order.
+func Tee(reporters ...Reporter) Reporter {
+	return tee(reporters)
+}
+
+type tee []Reporter
+
+func (t tee) Report(e Event) {
+	for _, r := range t {
+		r.Report(e)
+	}
+}
+
+func (t tee) Close() {
+	for _, r := range t {
+		r.Close()
+	}
+}
+
+// LogWriter reports each write as a log line of the running step.
+type LogWriter struct {
+	r Reporter
+}
+
+func NewLogWriter(r Reporter) *LogWriter {
+	return &LogWriter{r: r}
+}
+
+var _ io.Writer = (*LogWriter)(nil)
+var _ io.StringWriter = (*LogWriter)(nil)
+
+func (w LogWriter) Write(p []byte) (n int, err error) {
+	w.r.Report(FetchLog{Line: string(p)})
+	return len(p), nil
+}
+
+func (w LogWriter) WriteString(s string) (n int, err error) {
+	w.r.Report(FetchLog{Line: s})
+	return len(s), nil
+}
+
+// Plain renders one line per finished step, and nothing while a step is running.
+type Plain struct {
+	w io.Writer
+}
+
+func NewPlain(w io.Writer) *Plain {
+	return &Plain{w: w}
+}
+
+var _ Reporter = (*Plain)(nil)
+
+func (r *Plain) Report(e Event) {
+	switch e.Kind() {
+	case KindSucceeded:
+		_, _ = fmt.Fprintf(r.w, "%s %s\n", color.Green("✔"), e.Message())
+	case KindFailed:
+		_, _ = fmt.Fprintf(r.w, "%s %s\n", color.Red("✘"), e.Message())
+	case KindInfo:
+		_, _ = fmt.Fprint(r.w, e.Message())
+	}
+}
+
+func (r *Plain) Close() {}
@@ -0,0 +1,152 @@
+package progress
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/134130/gh-domino/gitobj"
+)
+
+var (
+	pr91 = gitobj.PullRequest{Number: 91, Title: "bar", BaseRefName: "stack-1", HeadRefName: "stack-2", State: gitobj.PullRequestStateOpen}
+	pr92 = gitobj.PullRequest{Number: 92, Title: "baz", BaseRefName: "stack-2", HeadRefName: "stack-3", State: gitobj.PullRequestStateOpen}
+	errX = errors.New("x")
+)
+
+// recorder keeps the events it receives, and how many times it was closed.
+type recorder struct {
+	events []Event
+	closed int
+}
+
+func (r *recorder) Report(e Event) { r.events = append(r.events, e) }
+func (r *recorder) Close()         { r.closed++ }
+
+func TestEvents(t *testing.T) {
+	testcases := []struct {
+		event   Event
+		kind    Kind
+		message string
+	}{
+		{Info{Text: "Pull Requests\n"}, KindInfo, "Pull Requests\n"},
+		{Success{Text: "No broken PRs found."}, KindSucceeded, "No broken PRs found."},
+		{Failure{Text: "boom"}, KindFailed, "boom"},
+
+		{FetchStarted{}, KindStarted, "Fetching pull requests..."},
+		{FetchLog{Line: "git fetch origin"}, KindLog, "git fetch origin"},
+		{FetchDone{}, KindSucceeded, "Fetching pull requests..."},
+		{FetchFailed{Err: errX}, KindStopped, "Fetching pull requests..."},
+
+		{RebaseStarted{PR: pr91, NewBase: "main"}, KindStarted, "Rebasing #91 bar (stack-1 ← stack-2) onto main..."},
+		{PRRebased{PR: pr91, NewBase: "main"}, KindSucceeded, "Rebasing #91 bar (stack-1 ← stack-2) onto main..."},
+		{RebaseFailed{PR: pr91, NewBase: "main", Err: errX}, KindStopped, "Rebasing #91 bar (stack-1 ← stack-2) onto main..."},
+
+		{PushStarted{PRs: []gitobj.PullRequest{pr91}}, KindStarted, "Pushing #91..."},
+		{PRPushed{PRs: []gitobj.PullRequest{pr91, pr92}}, KindSucceeded, "Pushing #91, #92 atomically..."},
+		{PushFailed{PRs: []gitobj.PullRequest{pr91}, Err: errX}, KindStopped, "Pushing #91..."},
+
+		{BaseUpdateStarted{PR: pr91, NewBase: "main"}, KindStarted, "Updating base branch of #91 to main..."},
+		{BaseUpdated{PR: pr91, NewBase: "main"}, KindSucceeded, "Updating base branch of #91 to main..."},
+		{BaseUpdateFailed{PR: pr91, NewBase: "main", Err: errX}, KindStopped, "Updating base branch of #91 to main..."},
+
+		{CloseStarted{PR: pr91}, KindStarted, "Closing #91 as it has no changes left after the rebase..."},
+		{PRClosed{PR: pr91}, KindSucceeded, "Closing #91 as it has no changes left after the rebase..."},
+		{CloseFailed{PR: pr91, Err: errX}, KindStopped, "Closing #91 as it has no changes left after the rebase..."},
+
+		{DeleteBranchStarted{Branch: "stack-2"}, KindStarted, "Deleting branch stack-2..."},
+		{BranchDeleted{Branch: "stack-2"}, KindSucceeded, "Deleting branch stack-2..."},
+		{DeleteBranchFailed{Branch: "stack-2", Err: errX}, KindStopped, "Deleting branch stack-2..."},
+
+		{HookStarted{Hook: "pre-cascade"}, KindStarted, "Running pre-cascade hook..."},
+		{HookDone{Hook: "post-push", PR: &pr91}, KindSucceeded, "Running post-push hook for #91..."},
+		{HookFailed{Hook: "post-push", PR: &pr91, Err: errX}, KindStopped, "Running post-push hook for #91..."},
+
+		{ExecStarted{PR: pr91}, KindStarted, "Verifying #91..."},
+		{ExecDone{PR: pr91, Commit: "9ed382ac37edd384a6527390e246c99531b30642"}, KindSucceeded, "Verifying #91 at 9ed382a..."},
+		{ExecFailed{PR: pr91, Commit: "9ed382a", Err: errX}, KindStopped, "Verifying #91 at 9ed382a..."},
+	}
+
+	for _, tc := range testcases {
+		assert.Equal(t, tc.kind, tc.event.Kind(), "%T", tc.event)
+		assert.Equal(t, tc.message, tc.event.Message(), "%T", tc.event)
+	}
+}
+
+func TestPlain(t *testing.T) {
+	out := &bytes.Buffer{}
+	r := NewPlain(out)
+	r.Report(Info{Text: "Pull Requests\n"})
+	// Nothing is shown while a step is running, nor when it stops on an error reported on its own.
+	r.Report(RebaseStarted{PR: pr91, NewBase: "main"})
+	r.Report(FetchLog{Line: "Rebasing (1/1)"})
+	r.Report(PRRebased{PR: pr91, NewBase: "main"})
+	r.Report(PushStarted{PRs: []gitobj.PullRequest{pr91}})
+	r.Report(PushFailed{PRs: []gitobj.PullRequest{pr91}, Err: errX})
+	r.Report(Failure{Text: "failed to push"})
+	r.Report(Success{Text: "done"})
+	r.Close()
+
+	assert.Equal(t, `Pull Requests
+✔ Rebasing #91 bar (stack-1 ← stack-2) onto main...
+✘ failed to push
+✔ done
+`, out.String())
+}
+
+func TestTTYReporter(t *testing.T) {
+	out := &bytes.Buffer{}
+	r := newTTYReporter(t.Context(), func() {}, out)
+	r.Report(Info{Text: "Pull Requests\n"})
+	r.Report(FetchStarted{})
+	r.Report(FetchLog{Line: "git fetch origin"})
+	r.Report(FetchDone{})
+	r.Report(RebaseStarted{PR: pr91, NewBase: "main"})
+	r.Report(RebaseFailed{PR: pr91, NewBase: "main", Err: errX})
+	r.Report(Failure{Text: "conflict"})
+	r.Report(PushStarted{PRs: []gitobj.PullRequest{pr91}})
+	r.Close()
+	r.Close()
+
+	// The view of each step is cleared once it ends, and only the outcomes stay.
+	got := out.String()
+	assert.True(t, strings.HasPrefix(got, "Pull Requests\n"), got)
+	assert.Contains(t, got, "✔ Fetching pull requests...\n")
+	assert.Contains(t, got, "✘ conflict\n")
+	assert.NotContains(t, got, "✔ Rebasing")
+	assert.Less(t, strings.Index(got, "✔ Fetching"), strings.Index(got, "✘ conflict"))
+	assert.Nil(t, r.program)
+}
+
+func TestLogWriter(t *testing.T) {
+	r := &recorder{}
+	w := NewLogWriter(r)
+
+	n, err := w.Write([]byte("git fetch origin"))
+	assert.NoError(t, err)
+	assert.Equal(t, 16, n)
+	n, err = w.WriteString("From github.com:134130/test-domino")
+	assert.NoError(t, err)
+	assert.Equal(t, 34, n)
+
+	assert.Equal(t, []Event{
+		FetchLog{Line: "git fetch origin"},
+		FetchLog{Line: "From github.com:134130/test-domino"},
+	}, r.events)
+}
+
+func TestTee(t *testing.T) {
+	a, b := &recorder{}, &recorder{}
+	r := Tee(a, Discard(), b)
+	r.Report(FetchStarted{})
+	r.Report(FetchDone{})
+	r.Close()
+
+	for _, rec := range []*recorder{a, b} {
+		assert.Equal(t, []Event{FetchStarted{}, FetchDone{}}, rec.events)
+		assert.Equal(t, 1, rec.closed)
+	}
+}
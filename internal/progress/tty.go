@@ -0,0 +1,82 @@
+package progress
+
+import (
+	"context"
+	"io"
+	"sync"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/134130/gh-domino/internal/ui"
+)
+
+// ttyReporter shows a spinner with the latest log lines while a step is running,
+// then replaces it with the outcome of the step.
+// The view only runs along with a step, so prompts in between don't fight over the terminal.
+type ttyReporter struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	plain  *Plain
+
+	mu      sync.Mutex
+	program *tea.Program
+	done    chan struct{}
+}
+
+func newTTYReporter(ctx context.Context, cancel context.CancelFunc, w io.Writer) *ttyReporter {
+	return &ttyReporter{
+		ctx:    ctx,
+		cancel: cancel,
+		plain:  NewPlain(w),
+	}
+}
+
+var _ Reporter = (*ttyReporter)(nil)
+
+func (r *ttyReporter) Report(e Event) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	switch e.Kind() {
+	case KindStarted:
+		r.stop()
+		r.start(e.Message())
+	case KindLog:
+		if r.program != nil {
+			r.program.Send(ui.LogMsg(e.Message()))
+		}
+	default:
+		r.stop()
+		r.plain.Report(e)
+	}
+}
+
+func (r *ttyReporter) Close() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.stop()
+}
+
+func (r *ttyReporter) start(title string) {
+	m := ui.NewModel(r.ctx, r.cancel, title)
+	p := tea.NewProgram(m, tea.WithOutput(r.plain.w))
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if _, err := p.Run(); err != nil {
+			r.cancel()
+		}
+	}()
+	r.program = p
+	r.done = done
+}
+
+func (r *ttyReporter) stop() {
+	if r.program == nil {
+		return
+	}
+	r.program.Send(ui.DoneMsg{})
+	<-r.done
+	r.program = nil
+	r.done = nil
+}
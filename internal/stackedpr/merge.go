@@ -0,0 +1,88 @@
+package stackedpr
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/134130/gh-domino/git"
+	"github.com/134130/gh-domino/gitobj"
+)
+
+// DetectMergeStrategy tells how the PR was merged, and returns the upstream to rebase the PRs
+// based on it with `git rebase --onto <new base> <upstream>`. An empty upstream means that the
+// commits of the merged PR are in the new base, so a plain rebase skips them.
+//
+//   - MergeCommit: the head of the PR is an ancestor of the merge commit, or the merge commit has several parents
+//     whatever their order.
+//   - RebaseMerge: the merge commit introduces the same changes as the head of the PR, which has several commits.
+//   - Squash: otherwise. A single commit merged by rebase can't be told apart from a squash, and is handled the same way.
+func DetectMergeStrategy(ctx context.Context, mergedPR gitobj.PullRequest) (MergeStrategy, string, error) {
+	if mergedPR.MergeCommit.Sha == "" || len(mergedPR.Commits) == 0 {
+		return MergeStrategyUnknown, "", nil
+	}
+	mergeCommit := mergedPR.MergeCommit.Sha
+	head := mergedPR.Commits[len(mergedPR.Commits)-1].Oid
+
+	isAncestor, err := git.IsAncestor(ctx, head, mergeCommit)
+	if err != nil {
+		return MergeStrategyUnknown, "", err
+	}
+	if isAncestor {
+		return MergeStrategyMergeCommit, "", nil
+	}
+
+	// The PR was merged with a merge commit but its head isn't part of it, e.g. because it was
+	// rewritten when merging, so its commits must be dropped explicitly.
+	isMergeCommit, err := git.IsMergeCommit(ctx, mergeCommit)
+	if err != nil {
+		return MergeStrategyUnknown, "", err
+	}
+	if isMergeCommit {
+		return MergeStrategyMergeCommit, head, nil
+	}
+
+	if len(mergedPR.Commits) > 1 {
+		mergePatchID, err := git.PatchID(ctx, mergeCommit)
+		if err != nil {
+			return MergeStrategyUnknown, "", err
+		}
+		headPatchID, err := git.PatchID(ctx, head)
+		if err != nil {
+			return MergeStrategyUnknown, "", err
+		}
+		if mergePatchID != "" && mergePatchID == headPatchID {
+			return MergeStrategyRebaseMerge, head, nil
+		}
+	}
+	return MergeStrategySquash, head, nil
+}
+
+// FindRebasedUpstream returns the last commit of head which was merged by rebase with the PR,
+// so that `git rebase --onto <new base> <upstream>` only replays the commits of head on top of it.
+// The commits of head are matched against the commits rewritten by the rebase merge by their patch IDs,
+// which covers a head based on any version of the PR. It returns an empty upstream if none matches.
+func FindRebasedUpstream(ctx context.Context, mergedPR gitobj.PullRequest, head string) (string, error) {
+	mergeCommit := mergedPR.MergeCommit.Sha
+	rewritten, err := git.PatchIDs(ctx, fmt.Sprintf("%s~%d..%s", mergeCommit, len(mergedPR.Commits), mergeCommit))
+	if err != nil {
+		return "", err
+	}
+	isRewritten := make(map[string]bool, len(rewritten))
+	for _, commit := range rewritten {
+		isRewritten[commit.PatchID] = true
+	}
+
+	commits, err := git.PatchIDs(ctx, mergeCommit+".."+head)
+	if err != nil {
+		return "", err
+	}
+	upstream := ""
+	// From the oldest commit, the commits of the PR come first, then the own commits of head.
+	for i := len(commits) - 1; i >= 0; i-- {
+		if !isRewritten[commits[i].PatchID] {
+			break
+		}
+		upstream = commits[i].Commit
+	}
+	return upstream, nil
+}
@@ -0,0 +1,193 @@
+package stackedpr
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/134130/gh-domino/git"
+	"github.com/134130/gh-domino/gitobj"
+)
+
+type fakeOutput struct {
+	stdout   string
+	exitCode int
+}
+
+// fakeRunner answers each command with its output, and fails on any unexpected command.
+// The patch ID of a diff is the diff itself, and a log is made of "commit <sha>" lines each followed by its diff.
+type fakeRunner map[string]fakeOutput
+
+func (r fakeRunner) Run(_ context.Context, cmd string, args []string, mods ...git.CommandModifier) error {
+	c := &exec.Cmd{}
+	for _, mod := range mods {
+		mod(c)
+	}
+
+	command := cmd + " " + strings.Join(args, " ")
+	if command == "git patch-id --stable" {
+		diff, err := io.ReadAll(c.Stdin)
+		if err != nil {
+			return err
+		}
+		if !strings.HasPrefix(string(diff), "commit ") {
+			_, _ = fmt.Fprintf(c.Stdout, "%s 0000\n", diff)
+			return nil
+		}
+		lines := strings.Split(strings.TrimSpace(string(diff)), "\n")
+		for i := 0; i+1 < len(lines); i += 2 {
+			_, _ = fmt.Fprintf(c.Stdout, "%s %s\n", lines[i+1], strings.TrimPrefix(lines[i], "commit "))
+		}
+		return nil
+	}
+	output, ok := r[command]
+	if !ok {
+		return fmt.Errorf("unexpected command %q", command)
+	}
+	if c.Stdout != nil {
+		_, _ = io.WriteString(c.Stdout, output.stdout)
+	}
+	if output.exitCode != 0 {
+		return &git.GitError{ExitCode: output.exitCode}
+	}
+	return nil
+}
+
+func mergedPR(mergeCommit string, commits ...string) gitobj.PullRequest {
+	pr := gitobj.PullRequest{Number: 1, State: gitobj.PullRequestStateMerged}
+	pr.MergeCommit.Sha = mergeCommit
+	for _, commit := range commits {
+		pr.Commits = append(pr.Commits, struct {
+			Oid string `json:"oid"`
+		}{Oid: commit})
+	}
+	return pr
+}
+
+func TestDetectMergeStrategy(t *testing.T) {
+	testcases := []struct {
+		name             string
+		pr               gitobj.PullRequest
+		runner           fakeRunner
+		expectedStrategy MergeStrategy
+		expectedUpstream string
+	}{{
+		name: "merge commit",
+		pr:   mergedPR("merge", "a", "b"),
+		runner: fakeRunner{
+			"git merge-base --is-ancestor b merge": {},
+		},
+		expectedStrategy: MergeStrategyMergeCommit,
+		expectedUpstream: "",
+	}, {
+		name: "merge commit without the head of the PR",
+		pr:   mergedPR("merge", "a", "b"),
+		runner: fakeRunner{
+			"git merge-base --is-ancestor b merge":   {exitCode: 1},
+			"git rev-parse --verify --quiet merge^2": {stdout: "c\n"},
+		},
+		expectedStrategy: MergeStrategyMergeCommit,
+		expectedUpstream: "b",
+	}, {
+		name: "squash",
+		pr:   mergedPR("squashed", "a", "b"),
+		runner: fakeRunner{
+			"git merge-base --is-ancestor b squashed":    {exitCode: 1},
+			"git rev-parse --verify --quiet squashed^2":  {exitCode: 1},
+			"git show --pretty=format: --patch squashed": {stdout: "a and b"},
+			"git show --pretty=format: --patch b":        {stdout: "b"},
+		},
+		expectedStrategy: MergeStrategySquash,
+		expectedUpstream: "b",
+	}, {
+		name: "squash of a single commit",
+		pr:   mergedPR("squashed", "a"),
+		runner: fakeRunner{
+			"git merge-base --is-ancestor a squashed":   {exitCode: 1},
+			"git rev-parse --verify --quiet squashed^2": {exitCode: 1},
+		},
+		expectedStrategy: MergeStrategySquash,
+		expectedUpstream: "a",
+	}, {
+		name: "rebase merge",
+		pr:   mergedPR("rebased-b", "a", "b"),
+		runner: fakeRunner{
+			"git merge-base --is-ancestor b rebased-b":    {exitCode: 1},
+			"git rev-parse --verify --quiet rebased-b^2":  {exitCode: 1},
+			"git show --pretty=format: --patch rebased-b": {stdout: "b"},
+			"git show --pretty=format: --patch b":         {stdout: "b"},
+		},
+		expectedStrategy: MergeStrategyRebaseMerge,
+		expectedUpstream: "b",
+	}, {
+		name:             "unknown without merge commit",
+		pr:               mergedPR("", "a"),
+		runner:           fakeRunner{},
+		expectedStrategy: MergeStrategyUnknown,
+		expectedUpstream: "",
+	}}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(tt *testing.T) {
+			original := git.CommandRunner
+			git.CommandRunner = tc.runner
+			tt.Cleanup(func() { git.CommandRunner = original })
+
+			strategy, upstream, err := DetectMergeStrategy(tt.Context(), tc.pr)
+			require.NoError(tt, err)
+			assert.Equal(tt, tc.expectedStrategy, strategy)
+			assert.Equal(tt, tc.expectedUpstream, upstream)
+		})
+	}
+}
+
+func TestFindRebasedUpstream(t *testing.T) {
+	testcases := []struct {
+		name     string
+		head     string
+		runner   fakeRunner
+		expected string
+	}{{
+		name: "based on the merged version",
+		head: "child",
+		runner: fakeRunner{
+			"git log --patch --no-color --pretty=medium merged~2..merged": {stdout: "commit merged\nb\ncommit rewritten-a\na\n"},
+			"git log --patch --no-color --pretty=medium merged..child":    {stdout: "commit child\nc\ncommit b\nb\ncommit a\na\n"},
+		},
+		expected: "b",
+	}, {
+		name: "based on an older version",
+		head: "child",
+		runner: fakeRunner{
+			"git log --patch --no-color --pretty=medium merged~2..merged": {stdout: "commit merged\nb\ncommit rewritten-a\na\n"},
+			"git log --patch --no-color --pretty=medium merged..child":    {stdout: "commit child\nc\ncommit a\na\n"},
+		},
+		expected: "a",
+	}, {
+		name: "own commit matching a merged one",
+		head: "child",
+		runner: fakeRunner{
+			"git log --patch --no-color --pretty=medium merged~2..merged": {stdout: "commit merged\nb\ncommit rewritten-a\na\n"},
+			"git log --patch --no-color --pretty=medium merged..child":    {stdout: "commit child\nb\ncommit c\nc\n"},
+		},
+		expected: "",
+	}}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(tt *testing.T) {
+			original := git.CommandRunner
+			git.CommandRunner = tc.runner
+			tt.Cleanup(func() { git.CommandRunner = original })
+
+			upstream, err := FindRebasedUpstream(tt.Context(), mergedPR("merged", "a", "b"), tc.head)
+			require.NoError(tt, err)
+			assert.Equal(tt, tc.expected, upstream)
+		})
+	}
+}
@@ -0,0 +1,69 @@
+package stackedpr
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/134130/gh-domino/git"
+	"github.com/134130/gh-domino/internal/color"
+)
+
+type RebaseOutcome int
+
+const (
+	// RebaseOutcomeUnknown means the rebase couldn't be simulated, e.g. because its parent conflicts.
+	RebaseOutcomeUnknown RebaseOutcome = iota
+	RebaseOutcomeClean
+	RebaseOutcomeConflict
+	// RebaseOutcomeEmpty means all the commits of the PR are already in the new base.
+	RebaseOutcomeEmpty
+)
+
+type RebasePrediction struct {
+	Outcome          RebaseOutcome
+	ConflictingFiles []string
+	// Head is a dangling commit holding the result of a clean rebase,
+	// so the PRs stacked on top can be simulated onto it.
+	Head string
+}
+
+func (p RebasePrediction) String() string {
+	switch p.Outcome {
+	case RebaseOutcomeClean:
+		return color.Green("clean")
+	case RebaseOutcomeConflict:
+		return color.Red(fmt.Sprintf("conflicts in %s", strings.Join(p.ConflictingFiles, ", ")))
+	case RebaseOutcomeEmpty:
+		return color.Yellow("empty after rebase")
+	default:
+		return color.Grey("unknown")
+	}
+}
+
+// PredictRebase simulates the rebase of headSha onto the given commit with `git merge-tree`,
+// without touching any ref or the working tree.
+func PredictRebase(ctx context.Context, info RebaseInfo, onto, headSha string) (RebasePrediction, error) {
+	tree, conflicts, err := git.MergeTree(ctx, info.Upstream, onto, headSha)
+	if err != nil {
+		return RebasePrediction{}, fmt.Errorf("failed to simulate rebase of %s: %w", info.PR.HeadRefName, err)
+	}
+	if len(conflicts) > 0 {
+		return RebasePrediction{Outcome: RebaseOutcomeConflict, ConflictingFiles: conflicts}, nil
+	}
+
+	ontoTree, err := git.RevParse(ctx, onto+"^{tree}")
+	if err != nil {
+		return RebasePrediction{}, fmt.Errorf("could not get tree of %s: %w", onto, err)
+	}
+
+	head, err := git.CommitTree(ctx, tree, onto, fmt.Sprintf("Simulated rebase of %s", info.PR.HeadRefName))
+	if err != nil {
+		return RebasePrediction{}, fmt.Errorf("failed to simulate rebase of %s: %w", info.PR.HeadRefName, err)
+	}
+
+	if tree == ontoTree {
+		return RebasePrediction{Outcome: RebaseOutcomeEmpty, Head: head}, nil
+	}
+	return RebasePrediction{Outcome: RebaseOutcomeClean, Head: head}, nil
+}
@@ -0,0 +1,116 @@
+package stackedpr
+
+import (
+	"fmt"
+
+	"github.com/134130/gh-domino/gitobj"
+	"github.com/134130/gh-domino/internal/color"
+)
+
+// BrokenReason tells why a PR needs to be rebased.
+type BrokenReason int
+
+const (
+	BrokenReasonNone BrokenReason = iota
+	// BrokenReasonBaseMerged is set when the base branch of the PR was merged.
+	BrokenReasonBaseMerged
+	// BrokenReasonBaseSquashMerged is set when the base branch of the PR was squash-merged,
+	// so its commits are not in the new base and must be dropped.
+	BrokenReasonBaseSquashMerged
+	// BrokenReasonBaseRebaseMerged is set when the base branch of the PR was merged by rebase,
+	// so its commits are in the new base with other SHAs and must be dropped.
+	BrokenReasonBaseRebaseMerged
+	// BrokenReasonBaseDiverged is set when the base branch of the PR moved away from the PR,
+	// e.g. because it was rebased.
+	BrokenReasonBaseDiverged
+	// BrokenReasonBaseRebased is set when the base branch of the PR is rebased in the same run.
+	BrokenReasonBaseRebased
+	// BrokenReasonBaseClosed is set when the parent PR was closed in the same run because it was empty.
+	BrokenReasonBaseClosed
+	// BrokenReasonContainsMergedCommits is set when the PR contains the commits of a merged PR.
+	BrokenReasonContainsMergedCommits
+)
+
+// MergeStrategy tells how a PR was merged.
+type MergeStrategy int
+
+const (
+	MergeStrategyUnknown MergeStrategy = iota
+	MergeStrategyMergeCommit
+	MergeStrategySquash
+	MergeStrategyRebaseMerge
+)
+
+func (s MergeStrategy) String() string {
+	switch s {
+	case MergeStrategyMergeCommit:
+		return "merge commit"
+	case MergeStrategySquash:
+		return "squash"
+	case MergeStrategyRebaseMerge:
+		return "rebase"
+	default:
+		return "unknown"
+	}
+}
+
+// Diagnosis tells whether a PR is broken and why, along with the evidence the decision was based on.
+// The evidence fields are only set when they were looked at.
+type Diagnosis struct {
+	Reason BrokenReason
+	// MergeBase is the merge base of the PR and its base branch.
+	MergeBase string
+	// ExpectedSha is the commit MergeBase should be at for the PR not to be broken.
+	ExpectedSha string
+	// MergedPR is the merged PR the decision was based on.
+	MergedPR *gitobj.PullRequest
+	// MergeStrategy is how MergedPR was merged.
+	MergeStrategy MergeStrategy
+}
+
+// IsBroken tells whether the PR needs to be rebased.
+func (d Diagnosis) IsBroken() bool {
+	return d.Reason != BrokenReasonNone
+}
+
+// Describe returns a concise description of the reason why the given PR is broken.
+func (d Diagnosis) Describe(info RebaseInfo) string {
+	base := color.Cyan(info.PR.BaseRefName)
+	switch d.Reason {
+	case BrokenReasonBaseMerged:
+		return fmt.Sprintf("base %s was merged", base)
+	case BrokenReasonBaseSquashMerged:
+		return fmt.Sprintf("base %s was squash-merged", base)
+	case BrokenReasonBaseRebaseMerged:
+		return fmt.Sprintf("base %s was rebase-merged", base)
+	case BrokenReasonBaseDiverged:
+		return fmt.Sprintf("base %s diverged", base)
+	case BrokenReasonBaseRebased:
+		return fmt.Sprintf("base %s needs a rebase first", base)
+	case BrokenReasonBaseClosed:
+		return fmt.Sprintf("base %s was closed as empty", base)
+	case BrokenReasonContainsMergedCommits:
+		return fmt.Sprintf("contains merged commits, to rebase onto %s", color.Cyan(info.NewBase))
+	default:
+		return "not broken"
+	}
+}
+
+// Evidence returns one "name: value" line per piece of evidence of the diagnosis.
+func (d Diagnosis) Evidence() []string {
+	var lines []string
+	if d.MergedPR != nil {
+		if d.MergeStrategy != MergeStrategyUnknown {
+			lines = append(lines, fmt.Sprintf("merged PR: %s (%s)", d.MergedPR.PRNumberString(), d.MergeStrategy))
+		} else {
+			lines = append(lines, fmt.Sprintf("merged PR: %s", d.MergedPR.PRNumberString()))
+		}
+	}
+	if d.MergeBase != "" {
+		lines = append(lines, fmt.Sprintf("merge base: %s", d.MergeBase))
+	}
+	if d.ExpectedSha != "" {
+		lines = append(lines, fmt.Sprintf("expected: %s", d.ExpectedSha))
+	}
+	return lines
+}
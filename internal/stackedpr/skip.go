@@ -0,0 +1,55 @@
+package stackedpr
+
+import (
+	"fmt"
+	"path"
+
+	"github.com/134130/gh-domino/gitobj"
+)
+
+const (
+	// SkipStateDraft skips the draft PRs.
+	SkipStateDraft = "draft"
+	// SkipStateReady skips the PRs ready for review, where force-pushing would drop the anchors of the review comments.
+	SkipStateReady = "ready"
+)
+
+// SkipRules tell which PRs must never be rewritten. Their children are still cascaded,
+// and stay stacked on them.
+type SkipRules struct {
+	// Labels skip the PRs labeled with any of them.
+	Labels []string
+	// State skips the PRs in this state, SkipStateDraft or SkipStateReady.
+	State string
+	// ProtectedBranches skip the PRs whose head branch matches any of these patterns, such as release/*.
+	ProtectedBranches []string
+}
+
+// Reason returns why the PR must be skipped, or an empty string if it must not.
+func (r SkipRules) Reason(pr gitobj.PullRequest) string {
+	for _, label := range r.Labels {
+		if pr.HasLabel(label) {
+			return fmt.Sprintf("labeled %s", label)
+		}
+	}
+	switch {
+	case r.State == SkipStateDraft && pr.IsDraft:
+		return "draft"
+	case r.State == SkipStateReady && !pr.IsDraft:
+		return "ready for review"
+	}
+	for _, pattern := range r.ProtectedBranches {
+		if matched, _ := path.Match(pattern, pr.HeadRefName); matched {
+			return fmt.Sprintf("protected branch %s", pr.HeadRefName)
+		}
+	}
+	return ""
+}
+
+// MarkSkipped sets the skip reason of each node of the dependency trees.
+func MarkSkipped(nodes []*Node, rules SkipRules) {
+	for _, node := range nodes {
+		node.SkipReason = rules.Reason(node.Value)
+		MarkSkipped(node.Children, rules)
+	}
+}
@@ -3,18 +3,22 @@ package stackedpr
 import (
 	"context"
 	"fmt"
+	"slices"
 
 	"github.com/134130/gh-domino/git"
 	"github.com/134130/gh-domino/gitobj"
+	"github.com/134130/gh-domino/internal/parallel"
 )
 
 type Node struct {
 	Value        gitobj.PullRequest
 	Children     []*Node
 	OriginalBase *gitobj.PullRequest
+	// SkipReason tells why the PR must not be rewritten, if it must not.
+	SkipReason string
 }
 
-func BuildDependencyTree(ctx context.Context, prs []gitobj.PullRequest, mergedPRs []gitobj.PullRequest, prHeadShas map[string]string) ([]*Node, error) {
+func BuildDependencyTree(ctx context.Context, prs []gitobj.PullRequest, mergedPRs []gitobj.PullRequest, prHeadShas map[string]string, trunks Trunks) ([]*Node, error) {
 	prMap := make(map[string]*Node)                           // HeadRefName -> Node
 	isChild := make(map[string]bool)                          // HeadRefName -> isChild
 	mergedPRsByHeadRef := make(map[string]gitobj.PullRequest) // HeadRefName -> Merged PR
@@ -50,6 +54,7 @@ func BuildDependencyTree(ctx context.Context, prs []gitobj.PullRequest, mergedPR
 	}
 
 	// Find original base
+	var candidates []*Node
 	for _, node := range prMap {
 		// If the base is another PR in the stack, skip (still part of the same stack).
 		if _, ok := prMap[node.Value.BaseRefName]; ok {
@@ -62,17 +67,22 @@ func BuildDependencyTree(ctx context.Context, prs []gitobj.PullRequest, mergedPR
 			continue
 		}
 
-		defaultBranch, err := git.GetDefaultBranch(ctx)
+		isTrunk, err := trunks.Contains(ctx, node.Value.BaseRefName)
 		if err != nil {
 			return nil, fmt.Errorf("could not get default branch: %w", err)
 		}
 
-		if node.Value.BaseRefName != defaultBranch {
-			// If the base is not the default branch, skip (not a root).
+		if !isTrunk {
+			// If the base is neither the default branch nor another trunk, skip (not a root).
 			continue
 		}
+		candidates = append(candidates, node)
+	}
 
-		// Heuristic: check if the PR's ancestor contains commits from a merged PR.
+	// Heuristic: check if the PR's ancestor contains commits from a PR merged into the same trunk.
+	// Each PR is checked on its own, so they are checked concurrently, while the merged PRs
+	// are tried in order for each of them.
+	_, _ = parallel.Map(ctx, candidates, parallel.DefaultLimit, func(ctx context.Context, node *Node) (struct{}, error) {
 		for i, mergedPR := range mergedPRs {
 			if len(mergedPR.Commits) == 0 {
 				continue
@@ -88,7 +98,8 @@ func BuildDependencyTree(ctx context.Context, prs []gitobj.PullRequest, mergedPR
 				break
 			}
 		}
-	}
+		return struct{}{}, nil
+	})
 
 	return roots, nil
 }
@@ -97,4 +108,31 @@ type RebaseInfo struct {
 	PR       gitobj.PullRequest
 	NewBase  string
 	Upstream string
+	// Diagnosis tells why the PR needs to be rebased.
+	Diagnosis Diagnosis
+	// OntoLocal is set when NewBase was rebased locally in this run and not pushed yet,
+	// so the remote-tracking branch is still pointing to the old commits.
+	OntoLocal bool
+	// Prediction is the simulated outcome of the rebase, only set in a dry run
+	// when conflict prediction is enabled.
+	Prediction *RebasePrediction
+}
+
+// Onto returns the ref the PR should be rebased onto.
+func (r RebaseInfo) Onto() string {
+	if r.OntoLocal {
+		return r.NewBase
+	}
+	return git.RemoteRef(r.NewBase)
+}
+
+// ChildrenOf returns the roots which were based on any of the merged PRs, with the PRs stacked on them.
+func ChildrenOf(roots []*Node, mergedPRs []int) []*Node {
+	var children []*Node
+	for _, root := range roots {
+		if root.OriginalBase != nil && slices.Contains(mergedPRs, root.OriginalBase.Number) {
+			children = append(children, root)
+		}
+	}
+	return children
 }
@@ -15,6 +15,15 @@ func RenderDependencyTree(nodes []*Node) string {
 		root.Child(asTreeNode(node))
 	}
 
+	return styled(root).String()
+}
+
+// RenderStack renders the stack of PRs based on the node, starting with the node itself.
+func RenderStack(node *Node) string {
+	return styled(asTreeNode(node)).String()
+}
+
+func styled(root *tree.Tree) *tree.Tree {
 	root.Indenter(func(children tree.Children, index int) string {
 		if children.Length()-1 == index {
 			return "  "
@@ -27,22 +36,27 @@ func RenderDependencyTree(nodes []*Node) string {
 		}
 		return "├─"
 	})
-
-	return root.String()
+	return root
 }
 
-func asTreeNode(node *Node) tree.Node {
+func asTreeNode(node *Node) *tree.Tree {
 	originalBaseStr := ""
 	if node.OriginalBase != nil {
 		originalBaseStr = fmt.Sprintf(" [was on %s]", node.OriginalBase.PRNumberString())
 	}
 
-	str := fmt.Sprintf("%s %s (%s ← %s)%s",
+	skippedStr := ""
+	if node.SkipReason != "" {
+		skippedStr = color.Grey(fmt.Sprintf(" [skipped: %s]", node.SkipReason))
+	}
+
+	str := fmt.Sprintf("%s %s (%s ← %s)%s%s",
 		node.Value.PRNumberString(),
 		node.Value.Title,
 		color.Cyan(node.Value.BaseRefName),
 		color.Blue(node.Value.HeadRefName),
 		originalBaseStr,
+		skippedStr,
 	)
 
 	t := tree.New()
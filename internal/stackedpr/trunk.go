@@ -0,0 +1,46 @@
+package stackedpr
+
+import (
+	"context"
+	"fmt"
+	"path"
+
+	"github.com/134130/gh-domino/git"
+)
+
+// Trunks are the patterns of the long-lived branches the stacks are based on besides the default branch,
+// such as release/*. Their PRs are roots, which are told to be broken by the merged PRs of the same trunk.
+type Trunks []string
+
+// Contains tells whether the branch is the default branch or matches any of the patterns.
+func (t Trunks) Contains(ctx context.Context, branch string) (bool, error) {
+	if t.Matches(branch) {
+		return true, nil
+	}
+	defaultBranch, err := git.GetDefaultBranch(ctx)
+	if err != nil {
+		return false, err
+	}
+	return branch == defaultBranch, nil
+}
+
+// Matches tells whether the branch matches any of the patterns, regardless of the default branch.
+func (t Trunks) Matches(branch string) bool {
+	for _, pattern := range t {
+		if matched, _ := path.Match(pattern, branch); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// MarkTrunks marks the PRs whose head branch is a trunk as skipped, unless a skip rule already did.
+// Other stacks are based on the trunks, so they are never rewritten, whatever the skip rules say.
+func MarkTrunks(nodes []*Node, trunks Trunks) {
+	for _, node := range nodes {
+		if node.SkipReason == "" && trunks.Matches(node.Value.HeadRefName) {
+			node.SkipReason = fmt.Sprintf("trunk branch %s", node.Value.HeadRefName)
+		}
+		MarkTrunks(node.Children, trunks)
+	}
+}
@@ -0,0 +1,24 @@
+package stackedpr
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/134130/gh-domino/gitobj"
+)
+
+func TestMarkTrunks(t *testing.T) {
+	release := &Node{Value: gitobj.PullRequest{Number: 1, BaseRefName: "main", HeadRefName: "release/1.x"}}
+	labeled := &Node{Value: gitobj.PullRequest{Number: 2, BaseRefName: "main", HeadRefName: "release/2.x"}, SkipReason: "labeled wip"}
+	child := &Node{Value: gitobj.PullRequest{Number: 3, BaseRefName: "release/1.x", HeadRefName: "stack-1"}}
+	release.Children = []*Node{child}
+
+	// The skip rules don't know about the trunks, which are marked on their own.
+	assert.Empty(t, SkipRules{ProtectedBranches: []string{"hotfix/*"}}.Reason(release.Value))
+
+	MarkTrunks([]*Node{release, labeled}, Trunks{"release/*"})
+	assert.Equal(t, "trunk branch release/1.x", release.SkipReason)
+	assert.Equal(t, "labeled wip", labeled.SkipReason)
+	assert.Empty(t, child.SkipReason)
+}
@@ -0,0 +1,244 @@
+package ui
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+
+	"github.com/134130/gh-domino/internal/color"
+)
+
+var (
+	cursorStyle = lipgloss.NewStyle().Bold(true)
+	helpStyle   = lipgloss.NewStyle().Foreground(lipgloss.Color("8"))
+)
+
+type PlanItemStatus int
+
+const (
+	PlanItemPending PlanItemStatus = iota
+	PlanItemRunning
+	PlanItemDone
+	PlanItemFailed
+	PlanItemSkipped
+)
+
+// PlanItem is a PR of the dependency tree, along with the rebase planned for it if it is broken.
+type PlanItem struct {
+	Label string
+	Depth int
+	// Broken tells whether a rebase is planned for the PR. Only broken PRs can be selected.
+	Broken   bool
+	Selected bool
+	// Bases are the branches the PR can be rebased onto, the planned one first.
+	Bases     []string
+	BaseIndex int
+	Status    PlanItemStatus
+	Err       error
+}
+
+func (i PlanItem) NewBase() string {
+	if len(i.Bases) == 0 {
+		return ""
+	}
+	return i.Bases[i.BaseIndex]
+}
+
+// Executor runs the planned rebase of the item at the given index onto the given base.
+type Executor func(ctx context.Context, index int, newBase string) error
+
+type planStepStartedMsg struct{ index int }
+
+type planStepDoneMsg struct {
+	index int
+	err   error
+}
+
+// PlanModel shows the dependency tree with the planned action of each broken PR,
+// lets the user toggle them and change their new base, then executes them in order
+// with live progress.
+type PlanModel struct {
+	*Model
+	items     []PlanItem
+	cursor    int
+	execute   Executor
+	executing bool
+	finished  bool
+	aborted   bool
+}
+
+func NewPlanModel(ctx context.Context, cancel context.CancelFunc, items []PlanItem, execute Executor) *PlanModel {
+	m := &PlanModel{
+		Model:   NewModel(ctx, cancel, ""),
+		items:   items,
+		execute: execute,
+	}
+	for i := range m.items {
+		m.items[i].Selected = m.items[i].Broken
+	}
+	return m
+}
+
+var _ tea.Model = (*PlanModel)(nil)
+
+// Items returns the items with their final status.
+func (m *PlanModel) Items() []PlanItem {
+	return m.items
+}
+
+// Aborted tells whether the user quit without executing the plan.
+func (m *PlanModel) Aborted() bool {
+	return m.aborted
+}
+
+func (m *PlanModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		if m.executing {
+			if msg.String() == "ctrl+c" {
+				m.cancel()
+			}
+			return m, nil
+		}
+		switch msg.String() {
+		case "ctrl+c", "q", "esc":
+			m.aborted = true
+			m.finished = true
+			return m, tea.Quit
+		case "up", "k":
+			m.moveCursor(-1)
+		case "down", "j":
+			m.moveCursor(1)
+		case " ", "x":
+			item := &m.items[m.cursor]
+			if item.Broken {
+				item.Selected = !item.Selected
+			}
+		case "b":
+			item := &m.items[m.cursor]
+			if item.Broken && len(item.Bases) > 0 {
+				item.BaseIndex = (item.BaseIndex + 1) % len(item.Bases)
+			}
+		case "enter":
+			m.executing = true
+			return m, m.next(0)
+		}
+		return m, nil
+	case planStepStartedMsg:
+		m.items[msg.index].Status = PlanItemRunning
+		return m, m.run(msg.index)
+	case planStepDoneMsg:
+		item := &m.items[msg.index]
+		if msg.err != nil {
+			item.Status = PlanItemFailed
+			item.Err = msg.err
+			// The PRs stacked on top can't be rebased on a failed one, so stop there.
+			for i := msg.index + 1; i < len(m.items); i++ {
+				if m.items[i].Selected {
+					m.items[i].Status = PlanItemSkipped
+				}
+			}
+			m.finished = true
+			return m, tea.Quit
+		}
+		item.Status = PlanItemDone
+		return m, m.next(msg.index + 1)
+	}
+
+	_, cmd := m.Model.Update(msg)
+	return m, cmd
+}
+
+// next starts the execution of the next selected item from the given index,
+// or quits once all of them are done.
+func (m *PlanModel) next(from int) tea.Cmd {
+	for i := from; i < len(m.items); i++ {
+		if m.items[i].Selected {
+			return func() tea.Msg {
+				return planStepStartedMsg{index: i}
+			}
+		}
+	}
+	m.finished = true
+	return tea.Quit
+}
+
+func (m *PlanModel) run(index int) tea.Cmd {
+	newBase := m.items[index].NewBase()
+	return func() tea.Msg {
+		return planStepDoneMsg{index: index, err: m.execute(m.ctx, index, newBase)}
+	}
+}
+
+func (m *PlanModel) moveCursor(delta int) {
+	m.cursor += delta
+	if m.cursor < 0 {
+		m.cursor = 0
+	}
+	if m.cursor >= len(m.items) {
+		m.cursor = len(m.items) - 1
+	}
+}
+
+func (m *PlanModel) View() string {
+	var sb strings.Builder
+	sb.WriteString(color.Bold("Pull Requests"))
+	sb.WriteString("\n")
+
+	for i, item := range m.items {
+		cursor := "  "
+		if !m.executing && !m.finished && i == m.cursor {
+			cursor = cursorStyle.Render("> ")
+		}
+		sb.WriteString(fmt.Sprintf("%s%s %s%s%s\n",
+			cursor,
+			m.marker(item),
+			strings.Repeat("  ", item.Depth),
+			item.Label,
+			m.action(item),
+		))
+	}
+
+	if !m.executing && !m.finished {
+		sb.WriteString("\n")
+		sb.WriteString(helpStyle.Render("↑/↓ move • space toggle • b change base • enter execute • q quit"))
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
+
+func (m *PlanModel) marker(item PlanItem) string {
+	switch item.Status {
+	case PlanItemRunning:
+		return m.spinner.View()
+	case PlanItemDone:
+		return color.Green("✔")
+	case PlanItemFailed:
+		return color.Red("✘")
+	case PlanItemSkipped:
+		return color.Grey("-")
+	}
+	if !item.Broken {
+		return " "
+	}
+	if item.Selected {
+		return "◉"
+	}
+	return "○"
+}
+
+func (m *PlanModel) action(item PlanItem) string {
+	if !item.Broken {
+		return ""
+	}
+	if item.Status == PlanItemFailed && item.Err != nil {
+		return color.Red(fmt.Sprintf(" → %v", item.Err))
+	}
+	if !item.Selected {
+		return color.Grey(" (skip)")
+	}
+	return fmt.Sprintf(" → rebase onto %s", color.Cyan(item.NewBase()))
+}
@@ -0,0 +1,123 @@
+package ui
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/stretchr/testify/assert"
+)
+
+type executed struct {
+	index   int
+	newBase string
+}
+
+func newTestPlanModel(t *testing.T, fail map[int]error) (*PlanModel, *[]executed) {
+	t.Helper()
+	var runs []executed
+	items := []PlanItem{
+		{Label: "#91 bar", Broken: true, Bases: []string{"main", "stack-1"}},
+		{Label: "#92 baz", Depth: 1, Broken: true, Bases: []string{"stack-2", "main"}},
+		{Label: "#93 qux", Depth: 2},
+		{Label: "#94 quux", Depth: 2, Broken: true, Bases: []string{"stack-3"}},
+	}
+	ctx, cancel := context.WithCancel(t.Context())
+	t.Cleanup(cancel)
+	m := NewPlanModel(ctx, cancel, items, func(_ context.Context, index int, newBase string) error {
+		runs = append(runs, executed{index: index, newBase: newBase})
+		return fail[index]
+	})
+	return m, &runs
+}
+
+func key(s string) tea.KeyMsg {
+	switch s {
+	case "enter":
+		return tea.KeyMsg{Type: tea.KeyEnter}
+	case "down":
+		return tea.KeyMsg{Type: tea.KeyDown}
+	case " ":
+		return tea.KeyMsg{Type: tea.KeySpace, Runes: []rune{' '}}
+	}
+	return tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(s)}
+}
+
+// press sends the keys to the model, then runs the commands they return like the program would,
+// until the model quits or has nothing left to do.
+func press(m *PlanModel, keys ...string) (quit bool) {
+	for _, k := range keys {
+		_, cmd := m.Update(key(k))
+		for cmd != nil {
+			msg := cmd()
+			if _, ok := msg.(tea.QuitMsg); ok {
+				return true
+			}
+			_, cmd = m.Update(msg)
+		}
+	}
+	return false
+}
+
+func statuses(m *PlanModel) []PlanItemStatus {
+	var s []PlanItemStatus
+	for _, item := range m.Items() {
+		s = append(s, item.Status)
+	}
+	return s
+}
+
+func TestPlanModel(t *testing.T) {
+	t.Run("executes the broken PRs in order", func(tt *testing.T) {
+		m, runs := newTestPlanModel(tt, nil)
+		assert.True(tt, press(m, "enter"))
+		assert.False(tt, m.Aborted())
+		assert.Equal(tt, []executed{{0, "main"}, {1, "stack-2"}, {3, "stack-3"}}, *runs)
+		assert.Equal(tt, []PlanItemStatus{PlanItemDone, PlanItemDone, PlanItemPending, PlanItemDone}, statuses(m))
+	})
+
+	t.Run("toggles the PRs and changes their base", func(tt *testing.T) {
+		m, runs := newTestPlanModel(tt, nil)
+		// Skip #91, rebase #92 onto main, and try to toggle #93, which is not broken.
+		assert.False(tt, press(m, " ", "down", "b", "down", " "))
+		assert.Equal(tt, []bool{false, true, false, true}, []bool{
+			m.Items()[0].Selected, m.Items()[1].Selected, m.Items()[2].Selected, m.Items()[3].Selected,
+		})
+		assert.True(tt, press(m, "enter"))
+		assert.Equal(tt, []executed{{1, "main"}, {3, "stack-3"}}, *runs)
+		assert.Equal(tt, []PlanItemStatus{PlanItemPending, PlanItemDone, PlanItemPending, PlanItemDone}, statuses(m))
+	})
+
+	t.Run("cycles through the bases", func(tt *testing.T) {
+		m, _ := newTestPlanModel(tt, nil)
+		press(m, "b")
+		assert.Equal(tt, "stack-1", m.Items()[0].NewBase())
+		press(m, "b")
+		assert.Equal(tt, "main", m.Items()[0].NewBase())
+	})
+
+	t.Run("stops on a failure", func(tt *testing.T) {
+		errConflict := errors.New("conflict")
+		m, runs := newTestPlanModel(tt, map[int]error{1: errConflict})
+		assert.True(tt, press(m, "enter"))
+		assert.Equal(tt, []executed{{0, "main"}, {1, "stack-2"}}, *runs)
+		assert.Equal(tt, []PlanItemStatus{PlanItemDone, PlanItemFailed, PlanItemPending, PlanItemSkipped}, statuses(m))
+		assert.Equal(tt, errConflict, m.Items()[1].Err)
+	})
+
+	t.Run("aborts without executing anything", func(tt *testing.T) {
+		m, runs := newTestPlanModel(tt, nil)
+		assert.True(tt, press(m, "q"))
+		assert.True(tt, m.Aborted())
+		assert.Empty(tt, *runs)
+	})
+
+	t.Run("ignores the keys while executing", func(tt *testing.T) {
+		m, _ := newTestPlanModel(tt, nil)
+		m.Update(key("enter"))
+		_, cmd := m.Update(key("q"))
+		assert.Nil(tt, cmd)
+		assert.False(tt, m.Aborted())
+	})
+}
@@ -15,17 +15,19 @@ var logBoxStyle = lipgloss.NewStyle().PaddingLeft(2).Foreground(lipgloss.Color("
 type Model struct {
 	ctx     context.Context
 	cancel  context.CancelFunc
+	title   string
 	spinner spinner.Model
 	logs    []string
 	done    bool
 }
 
-func NewModel(ctx context.Context, cancel context.CancelFunc) *Model {
+func NewModel(ctx context.Context, cancel context.CancelFunc, title string) *Model {
 	s := spinner.New()
 	s.Spinner = spinner.MiniDot
 	return &Model{
 		ctx:     ctx,
 		cancel:  cancel,
+		title:   title,
 		spinner: s,
 		logs:    make([]string, 0),
 	}
@@ -66,7 +68,7 @@ func (m *Model) View() string {
 	}
 	return lipgloss.JoinVertical(
 		lipgloss.Top,
-		fmt.Sprintf("%s Fetching pull requests...", m.spinner.View()),
+		fmt.Sprintf("%s %s", m.spinner.View(), m.title),
 		logBoxStyle.Render(strings.Join(m.logs, "\n")),
 	)
 }
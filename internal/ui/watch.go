@@ -0,0 +1,143 @@
+package ui
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+
+	"github.com/134130/gh-domino/internal/color"
+)
+
+var stackStatusStyle = lipgloss.NewStyle().PaddingLeft(3)
+
+// WatchStack is a stack of PRs shown on the dashboard.
+type WatchStack struct {
+	// Tree is the rendered dependency tree of the stack.
+	Tree string
+	// Status tells what the stack is waiting for, or how its last cascade ended.
+	Status string
+	// SyncedAt is when the stack was last cascaded, if ever.
+	SyncedAt time.Time
+}
+
+// WatchPolledMsg is sent once the PRs were polled, with the stacks they make.
+type WatchPolledMsg struct {
+	Stacks []WatchStack
+	At     time.Time
+	Err    error
+}
+
+// WatchBusyMsg tells what the watcher is doing, or that it is idle when empty.
+type WatchBusyMsg string
+
+// WatchModel is a live dashboard of the stacks, refreshed on each poll of the watcher,
+// along with the last things it did.
+type WatchModel struct {
+	*Model
+	interval time.Duration
+	stacks   []WatchStack
+	polledAt time.Time
+	err      error
+}
+
+func NewWatchModel(ctx context.Context, cancel context.CancelFunc, title string, interval time.Duration) *WatchModel {
+	return &WatchModel{
+		Model:    NewModel(ctx, cancel, title),
+		interval: interval,
+	}
+}
+
+var _ tea.Model = (*WatchModel)(nil)
+
+func (m *WatchModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		switch msg.String() {
+		case "ctrl+c", "q", "esc":
+			m.cancel()
+			m.done = true
+			return m, tea.Quit
+		}
+		return m, nil
+	case WatchPolledMsg:
+		m.polledAt = msg.At
+		m.err = msg.Err
+		if msg.Err == nil {
+			m.stacks = msg.Stacks
+		}
+		return m, nil
+	case WatchBusyMsg:
+		m.title = string(msg)
+		return m, nil
+	}
+
+	_, cmd := m.Model.Update(msg)
+	return m, cmd
+}
+
+func (m *WatchModel) View() string {
+	if m.done {
+		return ""
+	}
+
+	var sb strings.Builder
+	sb.WriteString(m.header())
+	sb.WriteString("\n\n")
+
+	if len(m.stacks) == 0 && !m.polledAt.IsZero() {
+		sb.WriteString(color.Grey("No open PRs."))
+		sb.WriteString("\n\n")
+	}
+	for _, stack := range m.stacks {
+		sb.WriteString(stack.Tree)
+		sb.WriteString("\n")
+		if status := stackStatus(stack); status != "" {
+			sb.WriteString(stackStatusStyle.Render(status))
+			sb.WriteString("\n")
+		}
+		sb.WriteString("\n")
+	}
+
+	if len(m.logs) > 0 {
+		sb.WriteString(logBoxStyle.Render(strings.Join(m.logs, "\n")))
+		sb.WriteString("\n\n")
+	}
+	sb.WriteString(helpStyle.Render("q quit"))
+	sb.WriteString("\n")
+	return sb.String()
+}
+
+func (m *WatchModel) header() string {
+	if m.title != "" {
+		return fmt.Sprintf("%s %s", m.spinner.View(), m.title)
+	}
+	if m.polledAt.IsZero() {
+		return fmt.Sprintf("%s Polling the PRs...", m.spinner.View())
+	}
+
+	header := fmt.Sprintf("Last polled at %s", m.polledAt.Format(time.TimeOnly))
+	if next := time.Until(m.polledAt.Add(m.interval)).Round(time.Second); next > 0 {
+		header += color.Grey(fmt.Sprintf(", next poll in %s", next))
+	}
+	if m.err != nil {
+		header += "\n" + color.Red(fmt.Sprintf("✘ %v", m.err))
+	}
+	return header
+}
+
+func stackStatus(stack WatchStack) string {
+	switch {
+	case stack.SyncedAt.IsZero() && stack.Status == "":
+		return ""
+	case stack.SyncedAt.IsZero():
+		return color.Grey(stack.Status)
+	case stack.Status == "":
+		return color.Grey(fmt.Sprintf("synced at %s", stack.SyncedAt.Format(time.TimeOnly)))
+	default:
+		return color.Grey(fmt.Sprintf("synced at %s: %s", stack.SyncedAt.Format(time.TimeOnly), stack.Status))
+	}
+}
@@ -1,7 +1,19 @@
 package test
 
 import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
 	"strings"
 	"testing"
 
@@ -187,8 +199,47 @@ Dry run mode enabled. The following PRs would be rebased:
 				tt.Fatalf("Integration test failed: %v", err)
 			}
 
-			_, after, _ := strings.Cut(out.String(), "[?1006l")
-			assert.Equal(tt, tc.expected, after)
+			assert.Equal(tt, tc.expected, out.String())
+		})
+	}
+}
+
+func TestDryRunPredictConflicts(t *testing.T) {
+	testcases := []struct {
+		name     string
+		expected string
+	}{{
+		name: "test-dry-run-predict-conflicts",
+		expected: `✔ Fetching pull requests...
+
+Pull Requests
+└─ #52 bar (stack-1 ← stack-2) [was on #51]
+   └─ #53 baz (stack-2 ← stack-3)
+
+Dry run mode enabled. The following PRs would be rebased:
+  #52 bar (stack-1 ← stack-2) (update base branch to main) [clean]
+  #53 baz (stack-2 ← stack-3) [conflicts in baz.txt]
+`,
+	}}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(tt *testing.T) {
+			cr, err := NewYAMLRunner(tt.Context(), fmt.Sprintf("testdata/%s.yaml", tc.name))
+			if err != nil {
+				tt.Fatalf("failed to create YAML runner: %v", err)
+			}
+			git.CommandRunner = cr
+
+			out := &strings.Builder{}
+			if err = domino.Run(tt.Context(), domino.Config{
+				DryRun:           ptr(true),
+				PredictConflicts: ptr(true),
+				Writer:           out,
+			}); err != nil {
+				tt.Fatalf("Integration test failed: %v", err)
+			}
+
+			assert.Equal(tt, tc.expected, out.String())
 		})
 	}
 }
@@ -238,6 +289,20 @@ Pull Requests
   Please resolve the conflicts manually and re-run the tool if needed.
   You can use the following command to rebase manually:
       git rebase --onto origin/main 2e6584b4cf5357c768400670d1a7ca89b862e0b7 feature-b
+`,
+	}, {
+		name: "test-auto-merge-empty",
+		expected: `✔ Fetching pull requests...
+
+Pull Requests
+└─ #94 bar (stack-1 ← stack-2) [was on #93]
+   └─ #95 baz (stack-2 ← stack-3)
+
+✔ Rebasing #94 bar (stack-1 ← stack-2) onto main...
+✔ Closing #94 as it has no changes left after the rebase...
+✔ Rebasing #95 baz (stack-2 ← stack-3) onto main...
+✔ Pushing #95...
+✔ Updating base branch of #95 to main...
 `,
 	}, {
 		name: "test-auto-merge-trunk",
@@ -272,8 +337,1310 @@ Pull Requests
 				tt.Fatalf("Integration test failed: %v", err)
 			}
 
-			_, after, _ := strings.Cut(out.String(), "[?1006l")
-			assert.Equal(tt, tc.expected, after)
+			assert.Equal(tt, tc.expected, out.String())
+		})
+	}
+}
+
+func TestAtomic(t *testing.T) {
+	const push = "git push --atomic --force-with-lease=stack-2:d7ac480203391969ca7908b777d74a49f319c7b2 --force-with-lease=stack-3:38afa1f03ccbcbacc778a0b4fdfb24fce6ddb9d5 origin stack-2 stack-3"
+	const edit = "gh pr edit 91 --base main"
+
+	testcases := []struct {
+		name     string
+		expected string
+		result   domino.Result
+	}{{
+		name: "test-auto-merge-atomic",
+		expected: `✔ Fetching pull requests...
+
+Pull Requests
+└─ #91 bar (stack-1 ← stack-2) [was on #90]
+   └─ #92 baz (stack-2 ← stack-3)
+
+✔ Rebasing #91 bar (stack-1 ← stack-2) onto main...
+✔ Rebasing #92 baz (stack-2 ← stack-3) onto stack-2...
+✔ Pushing #91, #92 atomically...
+✔ Updating base branch of #91 to main...
+`,
+		result: domino.ResultRebased,
+	}, {
+		name: "test-auto-merge-atomic-rejected",
+		expected: `✔ Fetching pull requests...
+
+Pull Requests
+└─ #91 bar (stack-1 ← stack-2) [was on #90]
+   └─ #92 baz (stack-2 ← stack-3)
+
+✔ Rebasing #91 bar (stack-1 ← stack-2) onto main...
+✔ Rebasing #92 baz (stack-2 ← stack-3) onto stack-2...
+✘ failed to push branches atomically: failed to run git: To github.com-134130:134130/test-domino
+ ! [remote rejected] stack-2 -> stack-2 (atomic push failure)
+ ! [remote rejected] stack-3 -> stack-3 (pre-receive hook declined)
+error: failed to push some refs to 'github.com-134130:134130/test-domino'
+
+`,
+		result: domino.ResultFailed,
+	}}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(tt *testing.T) {
+			cr, err := NewYAMLRunner(tt.Context(), fmt.Sprintf("testdata/%s.yaml", tc.name))
+			if err != nil {
+				tt.Fatalf("failed to create YAML runner: %v", err)
+			}
+			git.CommandRunner = cr
+
+			out := &strings.Builder{}
+			result, err := domino.RunWithResult(tt.Context(), domino.Config{
+				Auto:   ptr(true),
+				DryRun: ptr(false),
+				Atomic: ptr(true),
+				Writer: out,
+			})
+			if err != nil {
+				tt.Fatalf("Integration test failed: %v", err)
+			}
+			assert.Equal(tt, tc.expected, out.String())
+			assert.Equal(tt, tc.result, result)
+
+			// Both branches are pushed at once, after both were rebased, and the base
+			// branch is only updated once the push went through.
+			executed := cr.Executed()
+			pushes := slices.DeleteFunc(slices.Clone(executed), func(cmd string) bool {
+				return !strings.HasPrefix(cmd, "git push")
+			})
+			assert.Equal(tt, []string{push}, pushes)
+			assert.Greater(tt, slices.Index(executed, push), slices.Index(executed, "git rebase stack-2 stack-3"))
+			if tc.result == domino.ResultFailed {
+				assert.NotContains(tt, executed, edit)
+			} else {
+				assert.Greater(tt, slices.Index(executed, edit), slices.Index(executed, push))
+			}
+		})
+	}
+}
+
+func TestStaleLease(t *testing.T) {
+	testcases := []struct {
+		name     string
+		atomic   bool
+		expected string
+	}{{
+		name: "test-auto-merge-stale-lease",
+		expected: `✔ Fetching pull requests...
+
+Pull Requests
+└─ #91 bar (stack-1 ← stack-2) [was on #90]
+   └─ #92 baz (stack-2 ← stack-3)
+
+✔ Rebasing #91 bar (stack-1 ← stack-2) onto main...
+✘ someone pushed to stack-2 meanwhile (expected it at d7ac480203391969ca7908b777d74a49f319c7b2); fetch the new commits and re-run
+`,
+	}, {
+		name:   "test-auto-merge-atomic-stale-lease",
+		atomic: true,
+		expected: `✔ Fetching pull requests...
+
+Pull Requests
+└─ #91 bar (stack-1 ← stack-2) [was on #90]
+   └─ #92 baz (stack-2 ← stack-3)
+
+✔ Rebasing #91 bar (stack-1 ← stack-2) onto main...
+✔ Rebasing #92 baz (stack-2 ← stack-3) onto stack-2...
+✘ someone pushed to stack-3 meanwhile (expected it at 38afa1f03ccbcbacc778a0b4fdfb24fce6ddb9d5); fetch the new commits and re-run
+`,
+	}}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(tt *testing.T) {
+			cr, err := NewYAMLRunner(tt.Context(), fmt.Sprintf("testdata/%s.yaml", tc.name))
+			if err != nil {
+				tt.Fatalf("failed to create YAML runner: %v", err)
+			}
+			git.CommandRunner = cr
+
+			out := &strings.Builder{}
+			result, err := domino.RunWithResult(tt.Context(), domino.Config{
+				Auto:   ptr(true),
+				DryRun: ptr(false),
+				Atomic: ptr(tc.atomic),
+				Writer: out,
+			})
+			if err != nil {
+				tt.Fatalf("Integration test failed: %v", err)
+			}
+			assert.Equal(tt, tc.expected, out.String())
+			assert.Equal(tt, domino.ResultFailed, result)
+			assert.NotContains(tt, cr.Executed(), "gh pr edit 91 --base main")
+		})
+	}
+}
+
+func TestInteractive(t *testing.T) {
+	cr, err := NewYAMLRunner(t.Context(), "testdata/test-interactive-merge-empty.yaml")
+	if err != nil {
+		t.Fatalf("failed to create YAML runner: %v", err)
+	}
+	git.CommandRunner = cr
+
+	// Enter executes the plan as is.
+	result, err := domino.RunWithResult(t.Context(), domino.Config{
+		Interactive: ptr(true),
+		DryRun:      ptr(false),
+		Writer:      io.Discard,
+		Reader:      strings.NewReader("\r"),
+	})
+	if err != nil {
+		t.Fatalf("Integration test failed: %v", err)
+	}
+	assert.Equal(t, domino.ResultRebased, result)
+
+	// #95 was planned onto stack-2, which is closed by the time it is rebased.
+	executed := cr.Executed()
+	assert.Contains(t, executed, "git rebase --onto origin/main 321fe5965d81fab02f211ce2607ffadce4455621 stack-3")
+	assert.Contains(t, executed, "gh pr edit 95 --base main")
+}
+
+func TestDeleteBranches(t *testing.T) {
+	testcases := []struct {
+		name     string
+		expected string
+		deleted  bool
+	}{{
+		name: "test-auto-merge-empty-delete-branch",
+		expected: `✔ Fetching pull requests...
+
+Pull Requests
+└─ #94 bar (stack-1 ← stack-2) [was on #93]
+   └─ #95 baz (stack-2 ← stack-3)
+
+✔ Rebasing #94 bar (stack-1 ← stack-2) onto main...
+✔ Closing #94 as it has no changes left after the rebase...
+✔ Rebasing #95 baz (stack-2 ← stack-3) onto main...
+✔ Pushing #95...
+✔ Updating base branch of #95 to main...
+✔ Deleting branch stack-2...
+`,
+		deleted: true,
+	}, {
+		name: "test-auto-merge-empty-delete-branch-conflict",
+		expected: `✔ Fetching pull requests...
+
+Pull Requests
+└─ #94 bar (stack-1 ← stack-2) [was on #93]
+   └─ #95 baz (stack-2 ← stack-3)
+
+✔ Rebasing #94 bar (stack-1 ← stack-2) onto main...
+✔ Closing #94 as it has no changes left after the rebase...
+✘ Failed to handle broken PR #95 due to rebase conflicts.
+  Please resolve the conflicts manually and re-run the tool if needed.
+  You can use the following command to rebase manually:
+      git rebase --onto origin/main 321fe5965d81fab02f211ce2607ffadce4455621 stack-3
+Keeping branch stack-2, as PRs are still based on it.
+`,
+	}}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(tt *testing.T) {
+			cr, err := NewYAMLRunner(tt.Context(), fmt.Sprintf("testdata/%s.yaml", tc.name))
+			if err != nil {
+				tt.Fatalf("failed to create YAML runner: %v", err)
+			}
+			git.CommandRunner = cr
+
+			out := &strings.Builder{}
+			cfg := domino.Config{
+				Auto:   ptr(true),
+				DryRun: ptr(false),
+				Writer: out,
+			}
+			cfg.DeleteBranches = domino.DeleteBranchesClosed
+			if err := domino.Run(tt.Context(), cfg); err != nil {
+				tt.Fatalf("Integration test failed: %v", err)
+			}
+			assert.Equal(tt, tc.expected, out.String())
+
+			// The PR is closed without its branch, which is deleted once its child was retargeted.
+			executed := cr.Executed()
+			assert.Contains(tt, executed, "gh pr close 94 --comment All the commits of this pull request are already in `main`, so there is nothing left to merge. The pull requests based on it are retargeted to `main`.")
+			deletion := slices.Index(executed, "git push origin --delete stack-2")
+			if tc.deleted {
+				assert.Greater(tt, deletion, slices.Index(executed, "gh pr edit 95 --base main"))
+			} else {
+				assert.Equal(tt, -1, deletion)
+			}
+		})
+	}
+}
+
+func TestCI(t *testing.T) {
+	testcases := []struct {
+		name     string
+		cfg      domino.Config
+		expected domino.Result
+	}{{
+		name:     "test-dry-run-squash-1",
+		cfg:      domino.Config{DryRun: ptr(true)},
+		expected: domino.ResultNothingToDo,
+	}, {
+		name:     "test-dry-run-rebase-1",
+		cfg:      domino.Config{DryRun: ptr(true)},
+		expected: domino.ResultRebased,
+	}, {
+		name:     "test-dry-run-predict-conflicts",
+		cfg:      domino.Config{DryRun: ptr(true), PredictConflicts: ptr(true)},
+		expected: domino.ResultConflict,
+	}, {
+		name:     "test-auto-merge-1",
+		expected: domino.ResultRebased,
+	}, {
+		name:     "test-auto-merge-conflict",
+		expected: domino.ResultConflict,
+	}}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(tt *testing.T) {
+			cr, err := NewYAMLRunner(tt.Context(), fmt.Sprintf("testdata/%s.yaml", tc.name))
+			if err != nil {
+				tt.Fatalf("failed to create YAML runner: %v", err)
+			}
+			git.CommandRunner = cr
+
+			out := &strings.Builder{}
+			cfg := tc.cfg
+			cfg.CI = ptr(true)
+			cfg.Writer = out
+			result, err := domino.RunWithResult(tt.Context(), cfg)
+			if err != nil {
+				tt.Fatalf("Integration test failed: %v", err)
+			}
+
+			assert.Equal(tt, tc.expected, result)
+			assert.NotContains(tt, out.String(), "\x1b[")
+		})
+	}
+}
+
+func TestCheck(t *testing.T) {
+	testcases := []struct {
+		name     string
+		expected string
+		result   domino.Result
+	}{{
+		name: "test-dry-run-squash-1",
+		expected: `✔ Fetching pull requests...
+
+✔ No broken PRs found.
+`,
+		result: domino.ResultNothingToDo,
+	}, {
+		name: "test-dry-run-merge-commit-1",
+		expected: `✔ Fetching pull requests...
+
+✘ #56 bar (stack-1 ← stack-2): base stack-1 was merged
+✘ #57 baz (stack-2 ← stack-3): base stack-2 needs a rebase first
+`,
+		result: domino.ResultBroken,
+	}, {
+		name: "test-dry-run-squash-2",
+		expected: `✔ Fetching pull requests...
+
+✘ #52 bar (stack-1 ← stack-2): base stack-1 was squash-merged
+✘ #53 baz (stack-2 ← stack-3): base stack-2 needs a rebase first
+`,
+		result: domino.ResultBroken,
+	}, {
+		name: "test-dry-run-squash-3",
+		expected: `✔ Fetching pull requests...
+
+✘ #52 bar (main ← stack-2): contains merged commits, to rebase onto main
+✘ #53 baz (stack-2 ← stack-3): base stack-2 needs a rebase first
+`,
+		result: domino.ResultBroken,
+	}}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(tt *testing.T) {
+			cr, err := NewYAMLRunner(tt.Context(), fmt.Sprintf("testdata/%s.yaml", tc.name))
+			if err != nil {
+				tt.Fatalf("failed to create YAML runner: %v", err)
+			}
+			git.CommandRunner = cr
+
+			out := &strings.Builder{}
+			result, err := domino.RunWithResult(tt.Context(), domino.Config{
+				Command: domino.CommandCheck,
+				Writer:  out,
+			})
+			if err != nil {
+				tt.Fatalf("Integration test failed: %v", err)
+			}
+
+			assert.Equal(tt, tc.expected, out.String())
+			assert.Equal(tt, tc.result, result)
 		})
 	}
 }
+
+func TestExplain(t *testing.T) {
+	testcases := []struct {
+		name     string
+		fixture  string
+		cfg      domino.Config
+		expected string
+		result   domino.Result
+	}{{
+		name:    "explain-squash-merged-base",
+		fixture: "test-dry-run-squash-2",
+		cfg:     domino.Config{Command: domino.CommandExplain, PRNumber: 52},
+		expected: `✔ Fetching pull requests...
+
+✘ #52 bar (stack-1 ← stack-2) is broken
+  reason: base stack-1 was squash-merged
+  merged PR: #51 (squash)
+  upstream: 909d871a7213af3fde9dc48c0103fa23d08b2de5
+  new base: main
+`,
+		result: domino.ResultBroken,
+	}, {
+		name:    "explain-rebase-merged-base",
+		fixture: "test-dry-run-rebase-hard-2",
+		cfg:     domino.Config{Command: domino.CommandExplain, PRNumber: 76},
+		expected: `✔ Fetching pull requests...
+
+✘ #76 bar (stack-1 ← stack-2) is broken
+  reason: base stack-1 was rebase-merged
+  merged PR: #75 (rebase)
+  upstream: eb082bb8a73936c483fc8d933ad51154d4a5d975
+  new base: main
+`,
+		result: domino.ResultBroken,
+	}, {
+		name:    "explain-base-needs-rebase",
+		fixture: "test-dry-run-squash-3",
+		cfg:     domino.Config{Command: domino.CommandExplain, PRNumber: 53},
+		expected: `✔ Fetching pull requests...
+
+✘ #53 baz (stack-2 ← stack-3) is broken
+  reason: base stack-2 needs a rebase first
+  merge base: d878db1ed89159318c518fd53a96e75851ff91db
+  expected: d878db1ed89159318c518fd53a96e75851ff91db
+  new base: stack-2
+`,
+		result: domino.ResultBroken,
+	}, {
+		name:    "dry-run-verbose",
+		fixture: "test-dry-run-squash-3",
+		cfg:     domino.Config{DryRun: ptr(true), Verbose: ptr(true)},
+		expected: `✔ Fetching pull requests...
+
+Pull Requests
+└─ #52 bar (main ← stack-2) [was on #51]
+   └─ #53 baz (stack-2 ← stack-3)
+
+Dry run mode enabled. The following PRs would be rebased:
+  #52 bar (main ← stack-2)
+    reason: contains merged commits, to rebase onto main
+    merged PR: #51
+    merge base: 909d871a7213af3fde9dc48c0103fa23d08b2de5
+  #53 baz (stack-2 ← stack-3)
+    reason: base stack-2 needs a rebase first
+    merge base: d878db1ed89159318c518fd53a96e75851ff91db
+    expected: d878db1ed89159318c518fd53a96e75851ff91db
+`,
+		result: domino.ResultRebased,
+	}}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(tt *testing.T) {
+			cr, err := NewYAMLRunner(tt.Context(), fmt.Sprintf("testdata/%s.yaml", tc.fixture))
+			if err != nil {
+				tt.Fatalf("failed to create YAML runner: %v", err)
+			}
+			git.CommandRunner = cr
+
+			out := &strings.Builder{}
+			cfg := tc.cfg
+			cfg.Writer = out
+			result, err := domino.RunWithResult(tt.Context(), cfg)
+			if err != nil {
+				tt.Fatalf("Integration test failed: %v", err)
+			}
+
+			assert.Equal(tt, tc.expected, out.String())
+			assert.Equal(tt, tc.result, result)
+		})
+	}
+}
+
+func TestHooks(t *testing.T) {
+	testcases := []struct {
+		name     string
+		hooks    domino.Hooks
+		expected string
+		result   domino.Result
+	}{{
+		name: "test-auto-hooks",
+		hooks: domino.Hooks{
+			PreRebase:   "make generate",
+			PostRebase:  "go build ./...",
+			PostPush:    "./notify.sh",
+			PostCascade: "./summary.sh",
+		},
+		expected: `✔ Fetching pull requests...
+
+Pull Requests
+└─ #91 bar (stack-1 ← stack-2) [was on #90]
+   └─ #92 baz (stack-2 ← stack-3)
+
+✔ Running pre-rebase hook for #91...
+✔ Rebasing #91 bar (stack-1 ← stack-2) onto main...
+✔ Running post-rebase hook for #91...
+✔ Pushing #91...
+✔ Running post-push hook for #91...
+✔ Updating base branch of #91 to main...
+✔ Running pre-rebase hook for #92...
+✔ Rebasing #92 baz (stack-2 ← stack-3) onto stack-2...
+✔ Running post-rebase hook for #92...
+✔ Pushing #92...
+✔ Running post-push hook for #92...
+✔ Running post-cascade hook...
+`,
+		result: domino.ResultRebased,
+	}, {
+		name: "test-auto-hooks-veto",
+		hooks: domino.Hooks{
+			PostRebase:  "go build ./...",
+			PostCascade: "./summary.sh",
+		},
+		expected: `✔ Fetching pull requests...
+
+Pull Requests
+└─ #91 bar (stack-1 ← stack-2) [was on #90]
+   └─ #92 baz (stack-2 ← stack-3)
+
+✔ Rebasing #91 bar (stack-1 ← stack-2) onto main...
+✘ post-rebase hook ` + "`go build ./...`" + ` failed: command exited with 1
+  # github.com/134130/test-domino
+  ./bar.go:3:1: syntax error: non-declaration statement outside function body
+✔ Running post-cascade hook...
+`,
+		result: domino.ResultFailed,
+	}}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(tt *testing.T) {
+			cr, err := NewYAMLRunner(tt.Context(), fmt.Sprintf("testdata/%s.yaml", tc.name))
+			if err != nil {
+				tt.Fatalf("failed to create YAML runner: %v", err)
+			}
+			git.CommandRunner = cr
+
+			out := &strings.Builder{}
+			cfg := domino.Config{
+				Auto:   ptr(true),
+				Writer: out,
+			}
+			cfg.Hooks = tc.hooks
+			result, err := domino.RunWithResult(tt.Context(), cfg)
+			if err != nil {
+				tt.Fatalf("Integration test failed: %v", err)
+			}
+
+			assert.Equal(tt, tc.result, result)
+			assert.Equal(tt, tc.expected, out.String())
+		})
+	}
+}
+
+func TestExec(t *testing.T) {
+	testcases := []struct {
+		name       string
+		eachCommit bool
+		expected   string
+		result     domino.Result
+		// commands are the checkouts, the runs of the command and the pushes, in order.
+		commands []string
+	}{{
+		name: "test-auto-exec",
+		expected: `✔ Fetching pull requests...
+
+Pull Requests
+└─ #91 bar (stack-1 ← stack-2) [was on #90]
+   └─ #92 baz (stack-2 ← stack-3)
+
+✔ Rebasing #91 bar (stack-1 ← stack-2) onto main...
+✘ ` + "`go test ./...`" + ` failed on #91: command exited with 1
+  --- FAIL: TestBar (0.00s)
+  FAIL
+  #91 was not pushed. Its rebased branch is left as is in your local repository.
+`,
+		result:   domino.ResultFailed,
+		commands: []string{"sh -c go test ./..."},
+	}, {
+		name:       "test-auto-exec-each-commit",
+		eachCommit: true,
+		expected: `✔ Fetching pull requests...
+
+Pull Requests
+└─ #91 bar (stack-1 ← stack-2) [was on #90]
+   └─ #92 baz (stack-2 ← stack-3)
+
+✔ Rebasing #91 bar (stack-1 ← stack-2) onto main...
+✔ Verifying #91 at 9ed382a...
+✔ Pushing #91...
+✔ Updating base branch of #91 to main...
+✔ Rebasing #92 baz (stack-2 ← stack-3) onto stack-2...
+✔ Verifying #92 at dd03563...
+✔ Pushing #92...
+`,
+		result: domino.ResultRebased,
+		commands: []string{
+			"git checkout --quiet --detach 9ed382ac37edd384a6527390e246c99531b30642",
+			"sh -c go test ./...",
+			"git checkout --quiet stack-2",
+			"git push --force-with-lease=stack-2:d7ac480203391969ca7908b777d74a49f319c7b2 origin stack-2",
+			"git checkout --quiet --detach dd035635c9183f51f23588218a2f9167fad51401",
+			"sh -c go test ./...",
+			"git checkout --quiet stack-3",
+			"git push --force-with-lease=stack-3:38afa1f03ccbcbacc778a0b4fdfb24fce6ddb9d5 origin stack-3",
+		},
+	}, {
+		// The commits are verified from the oldest one, up to the second one which fails,
+		// then the branch is checked out back and left unpushed.
+		name:       "test-auto-exec-each-commit-failure",
+		eachCommit: true,
+		expected: `✔ Fetching pull requests...
+
+Pull Requests
+└─ #91 bar (stack-1 ← stack-2) [was on #90]
+
+✔ Rebasing #91 bar (stack-1 ← stack-2) onto main...
+✔ Verifying #91 at 754a750...
+✘ ` + "`go test ./...`" + ` failed on 4aea6583a3bf17f692c29fb555d701250c1ac12e of #91: command exited with 1
+  --- FAIL: TestBar (0.00s)
+      bar_test.go:7: Bar() = "BAR"
+  FAIL
+  FAIL	github.com/134130/test-domino	0.002s
+  FAIL
+  #91 was not pushed. Its rebased branch is left as is in your local repository.
+`,
+		result: domino.ResultFailed,
+		commands: []string{
+			"git checkout --quiet --detach 754a750edc2b5f7c9eab279d55c7d6c0b48e062a",
+			"sh -c go test ./...",
+			"git checkout --quiet --detach 4aea6583a3bf17f692c29fb555d701250c1ac12e",
+			"sh -c go test ./...",
+			"git checkout --quiet stack-2",
+		},
+	}}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(tt *testing.T) {
+			cr, err := NewYAMLRunner(tt.Context(), fmt.Sprintf("testdata/%s.yaml", tc.name))
+			if err != nil {
+				tt.Fatalf("failed to create YAML runner: %v", err)
+			}
+			git.CommandRunner = cr
+
+			out := &strings.Builder{}
+			result, err := domino.RunWithResult(tt.Context(), domino.Config{
+				Auto:           ptr(true),
+				Exec:           "go test ./...",
+				ExecEachCommit: ptr(tc.eachCommit),
+				Writer:         out,
+			})
+			if err != nil {
+				tt.Fatalf("Integration test failed: %v", err)
+			}
+
+			assert.Equal(tt, tc.result, result)
+			assert.Equal(tt, tc.expected, out.String())
+
+			var commands []string
+			for _, command := range cr.Executed() {
+				if strings.HasPrefix(command, "git checkout ") || strings.HasPrefix(command, "sh -c ") || strings.HasPrefix(command, "git push ") {
+					commands = append(commands, command)
+				}
+			}
+			assert.Equal(tt, tc.commands, commands)
+		})
+	}
+}
+
+func TestSkip(t *testing.T) {
+	testcases := []struct {
+		name     string
+		settings domino.Settings
+		dryRun   bool
+		expected string
+	}{{
+		name:     "labeled",
+		settings: domino.Settings{SkipLabels: []string{"do-not-rebase"}},
+		expected: `✔ Fetching pull requests...
+
+Pull Requests
+└─ #91 bar (stack-1 ← stack-2) [was on #90] [skipped: labeled do-not-rebase]
+   └─ #92 baz (stack-2 ← stack-3)
+
+Skipping #91 bar (stack-1 ← stack-2) (labeled do-not-rebase)
+✔ No broken PRs to rebase apart from the skipped ones.
+`,
+	}, {
+		name:     "ready for review",
+		settings: domino.Settings{SkipState: "ready"},
+		dryRun:   true,
+		expected: `✔ Fetching pull requests...
+
+Pull Requests
+└─ #91 bar (stack-1 ← stack-2) [was on #90] [skipped: ready for review]
+   └─ #92 baz (stack-2 ← stack-3) [skipped: ready for review]
+
+Dry run mode enabled. The following PRs would be rebased:
+  #91 bar (stack-1 ← stack-2) skipped (ready for review)
+✔ No broken PRs to rebase apart from the skipped ones.
+`,
+	}, {
+		name:     "protected branch",
+		settings: domino.Settings{ProtectedBranches: []string{"stack-2"}},
+		expected: `✔ Fetching pull requests...
+
+Pull Requests
+└─ #91 bar (stack-1 ← stack-2) [was on #90] [skipped: protected branch stack-2]
+   └─ #92 baz (stack-2 ← stack-3)
+
+Skipping #91 bar (stack-1 ← stack-2) (protected branch stack-2)
+✔ No broken PRs to rebase apart from the skipped ones.
+`,
+	}}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(tt *testing.T) {
+			cr, err := NewYAMLRunner(tt.Context(), "testdata/test-auto-skip.yaml")
+			if err != nil {
+				tt.Fatalf("failed to create YAML runner: %v", err)
+			}
+			git.CommandRunner = cr
+
+			out := &strings.Builder{}
+			cfg := domino.Config{
+				Auto:   ptr(!tc.dryRun),
+				DryRun: ptr(tc.dryRun),
+				Writer: out,
+			}
+			cfg.Settings = tc.settings
+			result, err := domino.RunWithResult(tt.Context(), cfg)
+			if err != nil {
+				tt.Fatalf("Integration test failed: %v", err)
+			}
+
+			assert.Equal(tt, domino.ResultNothingToDo, result)
+			assert.Equal(tt, tc.expected, out.String())
+		})
+	}
+}
+
+func TestCache(t *testing.T) {
+	expected := `✔ Fetching pull requests...
+
+Pull Requests
+└─ #56 bar (stack-1 ← stack-2) [was on #55]
+   └─ #57 baz (stack-2 ← stack-3)
+
+Dry run mode enabled. The following PRs would be rebased:
+  #56 bar (stack-1 ← stack-2) (update base branch to main)
+  #57 baz (stack-2 ← stack-3)
+`
+
+	// The runs share the cache, which each fixture tells where to find.
+	runners := make([]*YAMLRunner, 0, 3)
+	for _, name := range []string{"test-cache-cold", "test-cache-warm", "test-cache-updated"} {
+		cr, err := NewYAMLRunner(t.Context(), fmt.Sprintf("testdata/%s.yaml", name))
+		if err != nil {
+			t.Fatalf("failed to create YAML runner: %v", err)
+		}
+		runners = append(runners, cr)
+	}
+	t.Chdir(t.TempDir())
+
+	for i, name := range []string{"cold", "warm", "updated"} {
+		t.Run(name, func(tt *testing.T) {
+			git.CommandRunner = runners[i]
+
+			out := &strings.Builder{}
+			cfg := domino.Config{DryRun: ptr(true), Cache: ptr(true), Writer: out}
+			if err := domino.Run(tt.Context(), cfg); err != nil {
+				tt.Fatalf("Integration test failed: %v", err)
+			}
+
+			assert.Equal(tt, expected, out.String())
+		})
+	}
+}
+
+func TestDefaultBranch(t *testing.T) {
+	expected := `✔ Fetching pull requests...
+
+Pull Requests
+├─ #78 foo (main ← stack-1)
+│  └─ #79 bar (stack-1 ← stack-2)
+│     └─ #80 baz (stack-2 ← stack-3)
+└─ #82 bbb (feature-a ← feature-b) [was on #81]
+   └─ #83 ccc (feature-b ← feature-c)
+
+Dry run mode enabled. The following PRs would be rebased:
+  #82 bbb (feature-a ← feature-b) (update base branch to main)
+  #83 ccc (feature-b ← feature-c)
+`
+
+	testcases := []struct {
+		name          string
+		defaultBranch string
+		expectedErr   bool
+	}{{
+		name: "test-default-branch-provider",
+	}, {
+		name:          "test-default-branch-override",
+		defaultBranch: "main",
+	}, {
+		name:        "test-default-branch-unknown",
+		expectedErr: true,
+	}}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(tt *testing.T) {
+			cr, err := NewYAMLRunner(tt.Context(), fmt.Sprintf("testdata/%s.yaml", tc.name))
+			if err != nil {
+				tt.Fatalf("failed to create YAML runner: %v", err)
+			}
+			git.CommandRunner = cr
+
+			out := &strings.Builder{}
+			cfg := domino.Config{DryRun: ptr(true), Writer: out}
+			cfg.DefaultBranch = tc.defaultBranch
+			err = domino.Run(tt.Context(), cfg)
+			if tc.expectedErr {
+				var branchErr *git.DefaultBranchError
+				assert.ErrorAs(tt, err, &branchErr)
+				var headErr *git.RemoteHeadError
+				assert.ErrorAs(tt, err, &headErr)
+				var providerErr *git.ProviderDefaultBranchError
+				assert.ErrorAs(tt, err, &providerErr)
+				return
+			}
+			if err != nil {
+				tt.Fatalf("Integration test failed: %v", err)
+			}
+
+			assert.Equal(tt, expected, out.String())
+		})
+	}
+}
+
+func TestTrunks(t *testing.T) {
+	testcases := []struct {
+		name     string
+		fixture  string
+		trunks   []string
+		expected string
+	}{{
+		name:    "trunk",
+		fixture: "test-dry-run-trunk",
+		trunks:  []string{"release/*"},
+		expected: `✔ Fetching pull requests...
+
+Pull Requests
+└─ #52 bar (release/1.x ← stack-2) [was on #51]
+   └─ #53 baz (stack-2 ← stack-3)
+
+Dry run mode enabled. The following PRs would be rebased:
+  #52 bar (release/1.x ← stack-2)
+  #53 baz (stack-2 ← stack-3)
+`,
+	}, {
+		// Without the trunk, the stack is not known to be based on a merged PR.
+		name:    "no trunk",
+		fixture: "test-dry-run-trunk",
+		expected: `✔ Fetching pull requests...
+
+Pull Requests
+└─ #52 bar (release/1.x ← stack-2)
+   └─ #53 baz (stack-2 ← stack-3)
+
+Dry run mode enabled. The following PRs would be rebased:
+✔ No broken PRs found.
+`,
+	}}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(tt *testing.T) {
+			cr, err := NewYAMLRunner(tt.Context(), fmt.Sprintf("testdata/%s.yaml", tc.fixture))
+			if err != nil {
+				tt.Fatalf("failed to create YAML runner: %v", err)
+			}
+			git.CommandRunner = cr
+
+			out := &strings.Builder{}
+			cfg := domino.Config{DryRun: ptr(true), Writer: out}
+			cfg.TrunkBranches = tc.trunks
+			if err := domino.Run(tt.Context(), cfg); err != nil {
+				tt.Fatalf("Integration test failed: %v", err)
+			}
+
+			assert.Equal(tt, tc.expected, out.String())
+		})
+	}
+}
+
+func TestMergedPRs(t *testing.T) {
+	cr, err := NewYAMLRunner(t.Context(), "testdata/test-dry-run-multiple.yaml")
+	if err != nil {
+		t.Fatalf("failed to create YAML runner: %v", err)
+	}
+	git.CommandRunner = cr
+
+	out := &strings.Builder{}
+	cfg := domino.Config{DryRun: ptr(true), MergedPRs: []int{81}, Writer: out}
+	if err := domino.Run(t.Context(), cfg); err != nil {
+		t.Fatalf("Integration test failed: %v", err)
+	}
+
+	// Only the stack which was based on #81 is handled.
+	assert.Equal(t, `✔ Fetching pull requests...
+
+Pull Requests
+└─ #82 bbb (feature-a ← feature-b) [was on #81]
+   └─ #83 ccc (feature-b ← feature-c)
+
+Dry run mode enabled. The following PRs would be rebased:
+  #82 bbb (feature-a ← feature-b) (update base branch to main)
+  #83 ccc (feature-b ← feature-c)
+`, out.String())
+}
+
+func TestWebhook(t *testing.T) {
+	secret := []byte("It's a Secret to Everybody")
+	sign := func(payload []byte) string {
+		mac := hmac.New(sha256.New, secret)
+		mac.Write(payload)
+		return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+	}
+
+	testcases := []struct {
+		name      string
+		event     string
+		payload   string
+		signature func([]byte) string
+		status    int
+		jobs      []domino.Job
+	}{{
+		name:      "merged",
+		event:     "pull_request",
+		payload:   "webhook-pull-request-merged.json",
+		signature: sign,
+		status:    http.StatusAccepted,
+		jobs:      []domino.Job{{Repo: "134130/test-domino", MergedPR: 81, Author: "134130"}},
+	}, {
+		name:      "closed without merging",
+		event:     "pull_request",
+		payload:   "webhook-pull-request-closed.json",
+		signature: sign,
+		status:    http.StatusAccepted,
+	}, {
+		name:    "invalid signature",
+		event:   "pull_request",
+		payload: "webhook-pull-request-merged.json",
+		signature: func(payload []byte) string {
+			return sign(append(payload, '\n'))
+		},
+		status: http.StatusUnauthorized,
+	}, {
+		name:    "unsigned",
+		event:   "pull_request",
+		payload: "webhook-pull-request-merged.json",
+		signature: func([]byte) string {
+			return ""
+		},
+		status: http.StatusUnauthorized,
+	}, {
+		name:      "ping",
+		event:     "ping",
+		payload:   "webhook-pull-request-merged.json",
+		signature: sign,
+		status:    http.StatusNoContent,
+	}}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(tt *testing.T) {
+			payload, err := os.ReadFile(filepath.Join("..", "testdata", tc.payload))
+			if err != nil {
+				tt.Fatal(err)
+			}
+
+			var jobs []domino.Job
+			queue := domino.NewJobQueue(func(ctx context.Context, repo string, pending []domino.Job) {
+				jobs = append(jobs, pending...)
+			})
+			handler := domino.NewWebhookHandler(secret, queue)
+
+			req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(payload))
+			req.Header.Set("X-GitHub-Event", tc.event)
+			req.Header.Set("X-Hub-Signature-256", tc.signature(payload))
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			assert.Equal(tt, tc.status, rec.Code)
+
+			ctx, cancel := context.WithCancel(tt.Context())
+			cancel()
+			queue.Run(ctx)
+			assert.Equal(tt, tc.jobs, jobs)
+		})
+	}
+}
+
+func TestJobQueue(t *testing.T) {
+	type run struct {
+		repo string
+		prs  []int
+	}
+	var runs []run
+	done := make(chan struct{})
+	queue := domino.NewJobQueue(func(ctx context.Context, repo string, jobs []domino.Job) {
+		r := run{repo: repo}
+		for _, job := range jobs {
+			r.prs = append(r.prs, job.MergedPR)
+		}
+		runs = append(runs, r)
+		if len(runs) == 2 {
+			close(done)
+		}
+	})
+
+	// The jobs of a repository are run at once, in the order the repositories came in,
+	// and a job already pending is not run twice.
+	queue.Add(domino.Job{Repo: "octo/a", MergedPR: 1})
+	queue.Add(domino.Job{Repo: "octo/b", MergedPR: 2})
+	queue.Add(domino.Job{Repo: "octo/a", MergedPR: 3})
+	queue.Add(domino.Job{Repo: "octo/a", MergedPR: 1})
+
+	ctx, cancel := context.WithCancel(t.Context())
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		queue.Run(ctx)
+	}()
+	<-done
+	cancel()
+	<-stopped
+
+	assert.Equal(t, []run{{repo: "octo/a", prs: []int{1, 3}}, {repo: "octo/b", prs: []int{2}}}, runs)
+}
+
+func TestAction(t *testing.T) {
+	testcases := []struct {
+		name     string
+		fixture  string
+		event    string
+		result   domino.Result
+		exitCode int
+		summary  string
+	}{{
+		name:     "rebased",
+		fixture:  "test-auto-merge-1",
+		event:    "action-pull-request-merged-90.json",
+		result:   domino.ResultRebased,
+		exitCode: 0,
+		summary: "### gh-domino\n\n" +
+			"Cascaded the children of #90: **rebased**.\n\n" +
+			"| PR | Outcome |\n| --- | --- |\n" +
+			"| #91 bar | Rebased onto `main`, pushed, retargeted to `main` |\n" +
+			"| #92 baz | Rebased onto `stack-2`, pushed |\n\n",
+	}, {
+		name:     "conflict",
+		fixture:  "test-auto-merge-conflict",
+		event:    "action-pull-request-merged-114.json",
+		result:   domino.ResultConflict,
+		exitCode: 2,
+		summary: "### gh-domino\n\n" +
+			"Cascaded the children of #114: **conflict**.\n\n" +
+			"| PR | Outcome |\n| --- | --- |\n" +
+			"| #115 bbb | Failed to rebase onto `main` |\n\n" +
+			"```\nFailed to handle broken PR #115 due to rebase conflicts.\n" +
+			"  Please resolve the conflicts manually and re-run the tool if needed.\n" +
+			"  You can use the following command to rebase manually:\n" +
+			"      git rebase --onto origin/main 2e6584b4cf5357c768400670d1a7ca89b862e0b7 feature-b\n```\n\n",
+	}, {
+		name:   "closed without merging",
+		event:  "webhook-pull-request-closed.json",
+		result: domino.ResultNothingToDo,
+	}}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(tt *testing.T) {
+			if tc.fixture != "" {
+				cr, err := NewYAMLRunner(tt.Context(), fmt.Sprintf("testdata/%s.yaml", tc.fixture))
+				if err != nil {
+					tt.Fatalf("failed to create YAML runner: %v", err)
+				}
+				git.CommandRunner = cr
+			}
+
+			summaryPath := filepath.Join(tt.TempDir(), "summary.md")
+			tt.Setenv("GITHUB_EVENT_PATH", filepath.Join("..", "testdata", tc.event))
+			tt.Setenv("GITHUB_STEP_SUMMARY", summaryPath)
+			tt.Setenv("GH_TOKEN", "token")
+			// The action sets up the auth of git and gh, which must not leak into the other tests.
+			for _, env := range []string{
+				"GIT_CONFIG_COUNT", "GIT_CONFIG_KEY_0", "GIT_CONFIG_VALUE_0", "GIT_CONFIG_KEY_1", "GIT_CONFIG_VALUE_1",
+				"GIT_COMMITTER_NAME", "GIT_COMMITTER_EMAIL",
+			} {
+				tt.Setenv(env, "")
+			}
+
+			// The fixtures list the PRs of @me rather than those of the author of the merged PR.
+			cfg := domino.Config{Command: domino.CommandAction, Writer: &strings.Builder{}}
+			cfg.Author = "@me"
+			result, err := domino.RunAction(tt.Context(), cfg)
+			if err != nil {
+				tt.Fatalf("Integration test failed: %v", err)
+			}
+			assert.Equal(tt, tc.result, result)
+			assert.Equal(tt, tc.exitCode, result.ActionExitCode())
+
+			summary, err := os.ReadFile(summaryPath)
+			if tc.summary == "" {
+				assert.ErrorIs(tt, err, os.ErrNotExist)
+				return
+			}
+			if err != nil {
+				tt.Fatal(err)
+			}
+			assert.Equal(tt, tc.summary, string(summary))
+			assert.Equal(tt, "!gh auth git-credential", os.Getenv("GIT_CONFIG_VALUE_1"))
+		})
+	}
+}
+
+func TestWatch(t *testing.T) {
+	testcases := []struct {
+		name string
+		auto bool
+		// repo operates on the repository from its mirror, whose settings set the push options.
+		repo     string
+		expected string
+	}{{
+		name: "test-dry-run-multiple",
+		expected: `#81 was merged, run gh domino to rebase #82
+`,
+	}, {
+		name: "test-auto-merge-1",
+		auto: true,
+		expected: `
+Pull Requests
+└─ #91 bar (stack-1 ← stack-2) [was on #90]
+   └─ #92 baz (stack-2 ← stack-3)
+
+✔ Rebasing #91 bar (stack-1 ← stack-2) onto main...
+✔ Pushing #91...
+✔ Updating base branch of #91 to main...
+✔ Rebasing #92 baz (stack-2 ← stack-3) onto stack-2...
+✔ Pushing #92...
+Cascaded the children of #90: rebased
+`,
+	}, {
+		name: "test-watch-repo",
+		auto: true,
+		repo: "134130/test-domino",
+		expected: `
+Pull Requests
+└─ #91 bar (stack-1 ← stack-2) [was on #90]
+   └─ #92 baz (stack-2 ← stack-3)
+
+✔ Rebasing #91 bar (stack-1 ← stack-2) onto main...
+✔ Pushing #91...
+✔ Updating base branch of #91 to main...
+✔ Rebasing #92 baz (stack-2 ← stack-3) onto stack-2...
+✔ Pushing #92...
+Cascaded the children of #90: rebased
+`,
+	}}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(tt *testing.T) {
+			cr, err := NewYAMLRunner(tt.Context(), fmt.Sprintf("testdata/%s.yaml", tc.name))
+			if err != nil {
+				tt.Fatalf("failed to create YAML runner: %v", err)
+			}
+			git.CommandRunner = cr
+
+			if tc.repo != "" {
+				cache := tt.TempDir()
+				tt.Setenv("XDG_CACHE_HOME", cache)
+				tt.Setenv("XDG_CONFIG_HOME", tt.TempDir())
+				tt.Setenv("GH_REPO", "")
+				tt.Chdir(tt.TempDir())
+				tt.Cleanup(func() {
+					git.UseDefaultBranch("")
+					git.MetadataCache = nil
+				})
+
+				// The worktree left by a previous run, which the fixture detaches at the default branch.
+				worktree := filepath.Join(cache, "gh-domino", "repos", "github.com", tc.repo)
+				if err := os.MkdirAll(worktree, 0o755); err != nil {
+					tt.Fatal(err)
+				}
+				if err := os.WriteFile(filepath.Join(worktree, domino.SettingsFileName), []byte("push-options: [ci.skip]\n"), 0o644); err != nil {
+					tt.Fatal(err)
+				}
+				for i := range cr.Commands {
+					if cr.Commands[i].Command == "git rev-parse --show-toplevel" {
+						cr.Commands[i].Stdout = worktree
+					}
+				}
+			}
+
+			out := &strings.Builder{}
+			cfg := domino.Config{Command: domino.CommandWatch, Auto: ptr(tc.auto), CI: ptr(true), Repo: tc.repo, Writer: out}
+			if err := domino.NewWatcher(cfg).Poll(tt.Context()); err != nil {
+				tt.Fatalf("Integration test failed: %v", err)
+			}
+			assert.Equal(tt, tc.expected, out.String())
+		})
+	}
+}
+
+func TestWatchPolls(t *testing.T) {
+	testcases := []struct {
+		name    string
+		fixture string
+		// twice replays the fixture for the second poll, which finds the PRs as the first one did.
+		twice    bool
+		auto     bool
+		expected string
+	}{{
+		name:    "notified once",
+		fixture: "test-dry-run-multiple",
+		twice:   true,
+		expected: `#81 was merged, run gh domino to rebase #82
+`,
+	}, {
+		name:    "cascaded once",
+		fixture: "test-auto-merge-1",
+		twice:   true,
+		auto:    true,
+		expected: `
+Pull Requests
+└─ #91 bar (stack-1 ← stack-2) [was on #90]
+   └─ #92 baz (stack-2 ← stack-3)
+
+✔ Rebasing #91 bar (stack-1 ← stack-2) onto main...
+✔ Pushing #91...
+✔ Updating base branch of #91 to main...
+✔ Rebasing #92 baz (stack-2 ← stack-3) onto stack-2...
+✔ Pushing #92...
+Cascaded the children of #90: rebased
+`,
+	}, {
+		name:    "conflict not retried",
+		fixture: "test-auto-merge-conflict",
+		twice:   true,
+		auto:    true,
+		expected: `
+Pull Requests
+└─ #115 bbb (feature-a ← feature-b) [was on #114]
+
+✘ Failed to handle broken PR #115 due to rebase conflicts.
+  Please resolve the conflicts manually and re-run the tool if needed.
+  You can use the following command to rebase manually:
+      git rebase --onto origin/main 2e6584b4cf5357c768400670d1a7ca89b862e0b7 feature-b
+Cascaded the children of #114: conflict
+`,
+	}, {
+		name:    "failure retried",
+		fixture: "test-watch-retry",
+		auto:    true,
+		expected: `
+Pull Requests
+└─ #91 bar (stack-1 ← stack-2) [was on #90]
+   └─ #92 baz (stack-2 ← stack-3)
+
+✔ Rebasing #91 bar (stack-1 ← stack-2) onto main...
+✘ failed to push branch stack-2: failed to run git: fatal: the remote end hung up unexpectedly
+
+Cascaded the children of #90: failed, trying again on the next poll
+
+Pull Requests
+└─ #91 bar (stack-1 ← stack-2) [was on #90]
+   └─ #92 baz (stack-2 ← stack-3)
+
+✔ Rebasing #91 bar (stack-1 ← stack-2) onto main...
+✔ Pushing #91...
+✔ Updating base branch of #91 to main...
+✔ Rebasing #92 baz (stack-2 ← stack-3) onto stack-2...
+✔ Pushing #92...
+Cascaded the children of #90: rebased
+`,
+	}}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(tt *testing.T) {
+			cr, err := NewYAMLRunner(tt.Context(), fmt.Sprintf("testdata/%s.yaml", tc.fixture))
+			if err != nil {
+				tt.Fatalf("failed to create YAML runner: %v", err)
+			}
+			if tc.twice {
+				cr.Commands = append(cr.Commands, cr.Commands...)
+			}
+			git.CommandRunner = cr
+
+			out := &strings.Builder{}
+			w := domino.NewWatcher(domino.Config{Command: domino.CommandWatch, Auto: ptr(tc.auto), CI: ptr(true), Writer: out})
+			for range 2 {
+				if err := w.Poll(tt.Context()); err != nil {
+					tt.Fatalf("Integration test failed: %v", err)
+				}
+			}
+			assert.Equal(tt, tc.expected, out.String())
+		})
+	}
+}
+
+func TestNotify(t *testing.T) {
+	cr, err := NewYAMLRunner(t.Context(), "testdata/test-auto-merge-conflict.yaml")
+	if err != nil {
+		t.Fatalf("failed to create YAML runner: %v", err)
+	}
+	git.CommandRunner = cr
+
+	var payloads []map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload map[string]any
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			t.Error(err)
+		}
+		payloads = append(payloads, payload)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	cfg := domino.Config{CI: ptr(true), Writer: &strings.Builder{}}
+	cfg.Notify.Webhook = server.URL
+	result, err := domino.RunWithResult(t.Context(), cfg)
+	if err != nil {
+		t.Fatalf("Integration test failed: %v", err)
+	}
+	assert.Equal(t, domino.ResultConflict, result)
+
+	// The conflict is notified with the command to rebase by hand, without colors.
+	assert.Equal(t, []map[string]any{{
+		"event":      "rebase-conflict",
+		"pr":         float64(115),
+		"title":      "bbb",
+		"url":        "https://github.com/134130/test-domino/pull/115",
+		"headBranch": "feature-b",
+		"baseBranch": "feature-a",
+		"newBase":    "main",
+		"command":    "git rebase --onto origin/main 2e6584b4cf5357c768400670d1a7ca89b862e0b7 feature-b",
+	}}, payloads)
+}
@@ -7,7 +7,9 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"slices"
 	"strings"
+	"sync"
 
 	"github.com/goccy/go-yaml"
 
@@ -27,7 +29,9 @@ type YAMLCommand struct {
 
 type YAMLRunner struct {
 	Commands []YAMLCommand
+	mu       sync.Mutex
 	used     map[int]struct{}
+	executed []string
 }
 
 func NewYAMLRunner(ctx context.Context, filename string) (*YAMLRunner, error) {
@@ -59,21 +63,10 @@ func (r *YAMLRunner) Run(ctx context.Context, cmd string, args []string, mods ..
 		mod(execCmd)
 	}
 
-	var found *YAMLCommand
-	for i, c := range r.Commands {
-		if err := ctx.Err(); err != nil {
-			return err
-		}
-		if _, ok := r.used[i]; ok {
-			continue
-		}
-		if c.Command == fmt.Sprintf("%s %s", cmd, strings.Join(args, " ")) {
-			r.used[i] = struct{}{}
-			found = &c
-			break
-		}
+	found, err := r.take(ctx, fmt.Sprintf("%s %s", cmd, strings.Join(args, " ")))
+	if err != nil {
+		return err
 	}
-
 	if found == nil {
 		panic(fmt.Sprintf("could not find command '%s %s'", cmd, strings.Join(args, " ")))
 	}
@@ -96,15 +89,49 @@ func (r *YAMLRunner) Run(ctx context.Context, cmd string, args []string, mods ..
 		case "git":
 			return &git.GitError{
 				ExitCode: found.ExitCode,
-				Stderr:   "",
+				Stderr:   found.Stderr,
 			}
 		case "gh":
 			return &git.GHError{
 				ExitCode: found.ExitCode,
-				Stderr:   "",
+				Stderr:   found.Stderr,
+			}
+		case "sh":
+			return &git.ShellError{
+				ExitCode: found.ExitCode,
+				Stderr:   found.Stderr,
 			}
 		}
 	}
 
 	return nil
 }
+
+// take returns the first unused command matching the given one, and marks it as used.
+// Commands may be run concurrently, so that is done under a lock.
+func (r *YAMLRunner) take(ctx context.Context, command string) (*YAMLCommand, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i, c := range r.Commands {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if _, ok := r.used[i]; ok {
+			continue
+		}
+		if c.Command == command {
+			r.used[i] = struct{}{}
+			r.executed = append(r.executed, command)
+			return &c, nil
+		}
+	}
+	return nil, nil
+}
+
+// Executed returns the commands run so far, in the order they were run.
+func (r *YAMLRunner) Executed() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return slices.Clone(r.executed)
+}